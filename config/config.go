@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -9,6 +12,16 @@ type Config struct {
 	Database  string
 	Frontend  string
 	JWTSecret string
+	// SchedulerInterval 周期记账调度器的检查间隔
+	SchedulerInterval time.Duration
+	// TrashRetention 回收站保留时长，超过后由调度器彻底删除；为 0 时不自动清理
+	TrashRetention time.Duration
+	// IdempotencyTTL 幂等键及其响应的保留时长
+	IdempotencyTTL time.Duration
+	// AttachmentDir 附件文件目录，默认为数据库所在目录下的 attachments
+	AttachmentDir string
+	// AttachmentMaxSize 单个附件的大小上限（字节）
+	AttachmentMaxSize int64
 }
 
 func Load() *Config {
@@ -28,10 +41,35 @@ func Load() *Config {
 	if jwtSecret == "" {
 		jwtSecret = "account-service-default-secret-change-in-production"
 	}
+	schedulerInterval, err := time.ParseDuration(os.Getenv("SCHEDULER_INTERVAL"))
+	if err != nil || schedulerInterval <= 0 {
+		schedulerInterval = time.Minute
+	}
+	retentionDays, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || retentionDays < 0 {
+		retentionDays = 30
+	}
+	idempotencyTTL, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || idempotencyTTL <= 0 {
+		idempotencyTTL = 24 * time.Hour
+	}
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = filepath.Join(filepath.Dir(dbPath), "attachments")
+	}
+	attachmentMaxMB, err := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_MB"))
+	if err != nil || attachmentMaxMB <= 0 {
+		attachmentMaxMB = 10
+	}
 	return &Config{
-		Port:      port,
-		Database:  dbPath,
-		Frontend:  frontend,
-		JWTSecret: jwtSecret,
+		Port:              port,
+		Database:          dbPath,
+		Frontend:          frontend,
+		JWTSecret:         jwtSecret,
+		SchedulerInterval: schedulerInterval,
+		TrashRetention:    time.Duration(retentionDays) * 24 * time.Hour,
+		IdempotencyTTL:    idempotencyTTL,
+		AttachmentDir:     attachmentDir,
+		AttachmentMaxSize: int64(attachmentMaxMB) << 20,
 	}
 }
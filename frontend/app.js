@@ -12,8 +12,6 @@ let recordsPageSize = 20;
 let deleteTargetId = null;
 let currentRecords = [];
 let currentSort = { field: 'date', dir: 'desc' }; // 默认按日期倒序
-let allFilteredRecords = [];
-let isAllRecordsSortMode = false;
 let currentSummaryData = null;
 let currentSummaryList = [];
 let currentSummaryPage = 1;
@@ -96,52 +94,16 @@ async function fetchRecords(page = 1, customPageSize = recordsPageSize) {
   if (startDate.value) params.set('start_date', startDate.value);
   if (endDate.value) params.set('end_date', endDate.value);
   if (keyword.value.trim()) params.set('keyword', keyword.value.trim());
+  params.set('sort', currentSort.field);
+  params.set('order', currentSort.dir);
 
   const res = await fetchAuth(`${API}/records?${params}`);
   if (!res.ok) throw new Error('获取列表失败');
   return res.json();
 }
 
-async function fetchAllFilteredRecords() {
-  const firstPage = await fetchRecords(1, recordsPageSize);
-  const total = Number(firstPage.total || 0);
-  const totalPages = Math.max(1, Math.ceil(total / recordsPageSize));
-  const list = [...(firstPage.data || [])];
-
-  for (let p = 2; p <= totalPages; p += 1) {
-    const next = await fetchRecords(p, recordsPageSize);
-    list.push(...(next.data || []));
-  }
-
-  allFilteredRecords = list;
-  return allFilteredRecords;
-}
-
-function sortRecords(list) {
-  if (!Array.isArray(list) || list.length === 0) return list;
-  const { field, dir } = currentSort;
-  const factor = dir === 'desc' ? -1 : 1;
-  return [...list].sort((a, b) => {
-    if (field === 'date') {
-      // YYYY-MM-DD 字符串比较即可
-      return a.date === b.date ? 0 : (a.date > b.date ? factor : -factor);
-    }
-    if (field === 'amount') {
-      const av = a.amount || 0;
-      const bv = b.amount || 0;
-      return av === bv ? 0 : (av > bv ? factor : -factor);
-    }
-    if (field === 'category') {
-      const av = (a.category || '').toString();
-      const bv = (b.category || '').toString();
-      return av === bv ? 0 : (av > bv ? factor : -factor);
-    }
-    return 0;
-  });
-}
-
 function renderTable(data) {
-  const list = sortRecords(data.data || []);
+  const list = data.data || [];
   if (list.length === 0) {
     tableBody.innerHTML = '<tr><td colspan="5" class="empty">暂无记录</td></tr>';
     return;
@@ -212,7 +174,6 @@ function renderPagination(total, page, pageSize, onPageChange = loadPage) {
 async function loadPage(page = 1) {
   tableBody.innerHTML = '<tr><td colspan="5" class="loading">加载中...</td></tr>';
   try {
-    isAllRecordsSortMode = false;
     const data = await fetchRecords(page);
     currentRecords = data.data || [];
     currentPage = page;
@@ -223,7 +184,11 @@ async function loadPage(page = 1) {
   }
 }
 
+// 新增记录的幂等键，每次打开表单生成一次，重复提交不会产生重复记录
+let createKey = '';
+
 function openAdd() {
+  createKey = window.crypto && crypto.randomUUID ? crypto.randomUUID() : Date.now() + '-' + Math.random().toString(36).slice(2);
   modalTitle.textContent = '添加记录';
   recordId.value = '';
   recordForm.reset();
@@ -233,17 +198,25 @@ function openAdd() {
   modal.classList.add('show');
 }
 
+// 编辑中记录的 ETag，保存时通过 If-Match 防止覆盖他人的修改
+let editETag = '';
+
+function fillEditForm(r) {
+  recordId.value = r.id;
+  formDate.value = r.date;
+  syncDateDisplay('formDate');
+  formAmount.value = r.amount != null ? r.amount.toFixed(2) : '';
+  formCategory.value = r.category || '';
+  formDescription.value = r.description || '';
+}
+
 async function openEdit(id) {
   try {
     const res = await fetchAuth(`${API}/records/${id}`);
     if (!res.ok) throw new Error('获取失败');
+    editETag = res.headers.get('ETag') || '';
     const r = await res.json();
-    recordId.value = r.id;
-    formDate.value = r.date;
-    syncDateDisplay('formDate');
-    formAmount.value = r.amount != null ? r.amount.toFixed(2) : '';
-    formCategory.value = r.category || '';
-    formDescription.value = r.description || '';
+    fillEditForm(r);
     modalTitle.textContent = '编辑记录';
     modal.classList.add('show');
   } catch (e) {
@@ -291,11 +264,21 @@ recordForm.addEventListener('submit', async (e) => {
     const url = id ? `${API}/records/${id}` : `${API}/records`;
     const method = id ? 'PUT' : 'POST';
     const body = id ? payload : payload;
+    const headers = { 'Content-Type': 'application/json' };
+    if (id && editETag) headers['If-Match'] = editETag;
+    if (!id && createKey) headers['Idempotency-Key'] = createKey;
     const res = await fetchAuth(url, {
       method,
-      headers: { 'Content-Type': 'application/json' },
+      headers,
       body: JSON.stringify(body),
     });
+    if (res.status === 412) {
+      const err = await res.json();
+      editETag = res.headers.get('ETag') || '';
+      fillEditForm(err.current);
+      alert('该记录已被其他人修改，已载入最新内容，请确认后重新保存');
+      return;
+    }
     if (!res.ok) {
       const err = await res.json().catch(() => ({}));
       throw new Error(err.error || '保存失败');
@@ -427,8 +410,6 @@ document.getElementById('btnReset').addEventListener('click', () => {
 if (recordsPageSizeSelect) {
   recordsPageSizeSelect.addEventListener('change', () => {
     recordsPageSize = Number(recordsPageSizeSelect.value) || 20;
-    isAllRecordsSortMode = false;
-    allFilteredRecords = [];
     loadPage(1);
   });
 }
@@ -478,39 +459,16 @@ document.getElementById('settingsModal').addEventListener('click', (e) => {
   if (e.target.id === 'settingsModal') e.target.classList.remove('show');
 });
 
-function renderSortedPage(page = 1) {
-  const sorted = sortRecords(allFilteredRecords);
-  const total = sorted.length;
-  const totalPages = Math.max(1, Math.ceil(total / recordsPageSize));
-  const safePage = Math.min(Math.max(page, 1), totalPages);
-  const start = (safePage - 1) * recordsPageSize;
-  const pageList = sorted.slice(start, start + recordsPageSize);
-
-  currentPage = safePage;
-  currentRecords = pageList;
-  renderTable({ data: pageList });
-  renderPagination(total, safePage, recordsPageSize, renderSortedPage);
-}
-
-async function changeSort(field) {
+// 排序由服务端完成（sort/order 参数），切换后从第一页重新加载
+function changeSort(field) {
   if (!field) return;
   if (currentSort.field === field) {
     currentSort.dir = currentSort.dir === 'desc' ? 'asc' : 'desc';
   } else {
     currentSort = { field, dir: 'desc' };
   }
-
-  tableBody.innerHTML = '<tr><td colspan="5" class="loading">排序中...</td></tr>';
-  try {
-    if (!isAllRecordsSortMode || allFilteredRecords.length === 0) {
-      await fetchAllFilteredRecords();
-    }
-    isAllRecordsSortMode = true;
-    renderSortedPage(1);
-    refreshRecordsSortIndicators();
-  } catch (e) {
-    tableBody.innerHTML = `<tr><td colspan="5" class="empty">排序失败: ${e.message}</td></tr>`;
-  }
+  refreshRecordsSortIndicators();
+  loadPage(1);
 }
 
 if (thDate) thDate.addEventListener('click', () => changeSort('date'));
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrAccountInUse 账户下仍有记录，无法删除
+var ErrAccountInUse = errors.New("账户下仍有记录，请改为归档")
+
+// ErrAccountCurrencyLocked 账户下已有记录，不能再修改币种
+var ErrAccountCurrencyLocked = errors.New("账户下已有记录，不能修改币种")
+
+func (db *DB) migrateAccounts() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'other',
+			opening_balance INTEGER NOT NULL DEFAULT 0, -- 单位：分
+			currency TEXT NOT NULL DEFAULT 'CNY',
+			archived INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_accounts_ledger ON accounts(ledger_id);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN account_id INTEGER`)
+	_, _ = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_account ON records(account_id, date)`)
+	return nil
+}
+
+const accountColumns = `id, ledger_id, name, type, opening_balance, currency, archived, created_at, updated_at`
+
+func scanAccount(s rowScanner) (*models.Account, error) {
+	var a models.Account
+	if err := s.Scan(&a.ID, &a.LedgerID, &a.Name, &a.Type, &a.OpeningBalance, &a.Currency, &a.Archived, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (db *DB) CreateAccount(a *models.Account) error {
+	a.Currency = strings.ToUpper(a.Currency)
+	res, err := db.conn.Exec(
+		`INSERT INTO accounts (ledger_id, name, type, opening_balance, currency) VALUES (?, ?, ?, ?, ?)`,
+		a.LedgerID, a.Name, a.Type, a.OpeningBalance, a.Currency,
+	)
+	if err != nil {
+		return err
+	}
+	id, _ := res.LastInsertId()
+	a.ID = id
+	return nil
+}
+
+// GetAccount 获取账本内的账户，不存在时返回 nil
+func (db *DB) GetAccount(ledgerID, id int64) (*models.Account, error) {
+	a, err := scanAccount(db.conn.QueryRow(
+		`SELECT `+accountColumns+` FROM accounts WHERE id = ? AND ledger_id = ?`, id, ledgerID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (db *DB) ListAccounts(ledgerID int64, includeArchived bool) ([]*models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE ledger_id = ?`
+	if !includeArchived {
+		query += ` AND archived = 0`
+	}
+	rows, err := db.conn.Query(query+` ORDER BY archived, id`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Account
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+func (db *DB) UpdateAccount(ledgerID, id int64, req *models.UpdateAccountRequest) error {
+	a, err := db.GetAccount(ledgerID, id)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		return sql.ErrNoRows
+	}
+	if req.Name != nil {
+		a.Name = *req.Name
+	}
+	if req.Type != nil {
+		a.Type = *req.Type
+	}
+	if req.OpeningBalance != nil {
+		a.OpeningBalance = *req.OpeningBalance
+	}
+	if req.Currency != nil && strings.ToUpper(*req.Currency) != a.Currency {
+		var n int
+		if err := db.conn.QueryRow(`SELECT COUNT(*) FROM records WHERE account_id = ?`, id).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrAccountCurrencyLocked
+		}
+		a.Currency = strings.ToUpper(*req.Currency)
+	}
+	if req.Archived != nil {
+		a.Archived = *req.Archived
+	}
+	_, err = db.conn.Exec(
+		`UPDATE accounts SET name=?, type=?, opening_balance=?, currency=?, archived=?, updated_at=CURRENT_TIMESTAMP
+		 WHERE id=? AND ledger_id=?`,
+		a.Name, a.Type, a.OpeningBalance, a.Currency, a.Archived, id, ledgerID,
+	)
+	return err
+}
+
+// DeleteAccount 删除账户，仍被记录（含回收站中的记录）引用时返回 ErrAccountInUse
+func (db *DB) DeleteAccount(ledgerID, id int64) error {
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM records WHERE account_id = ?`, id).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrAccountInUse
+	}
+	res, err := db.conn.Exec(`DELETE FROM accounts WHERE id=? AND ledger_id=?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AccountBalances 各账户截至 date（含）的余额及截至 today 的当前余额
+func (db *DB) AccountBalances(ledgerID int64, date, today string, includeArchived bool) ([]*models.AccountBalance, error) {
+	query := `
+		SELECT a.id, a.name, a.type, a.currency, a.archived, a.opening_balance,
+			a.opening_balance + COALESCE(SUM(CASE WHEN r.date <= ? THEN r.amount ELSE 0 END), 0),
+			a.opening_balance + COALESCE(SUM(CASE WHEN r.date <= ? THEN r.amount ELSE 0 END), 0)
+		FROM accounts a LEFT JOIN records r ON r.account_id = a.id AND r.ledger_id = a.ledger_id AND r.deleted_at IS NULL
+		WHERE a.ledger_id = ?`
+	if !includeArchived {
+		query += ` AND a.archived = 0`
+	}
+	rows, err := db.conn.Query(query+` GROUP BY a.id ORDER BY a.archived, a.id`, date, today, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.AccountBalance
+	for rows.Next() {
+		var b models.AccountBalance
+		if err := rows.Scan(&b.AccountID, &b.Name, &b.Type, &b.Currency, &b.Archived, &b.OpeningBalance, &b.Balance, &b.CurrentBalance); err != nil {
+			return nil, err
+		}
+		list = append(list, &b)
+	}
+	return list, nil
+}
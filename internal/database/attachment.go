@@ -0,0 +1,130 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+)
+
+func (db *DB) migrateAttachments() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			record_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			sha256 TEXT NOT NULL,
+			storage_key TEXT NOT NULL,
+			thumb_key TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_attachments_record ON attachments(record_id);
+	`)
+	return err
+}
+
+const attachmentColumns = `id, ledger_id, record_id, user_id, filename, content_type, size, sha256, storage_key, thumb_key, created_at`
+
+func scanAttachment(s rowScanner) (*models.Attachment, error) {
+	var a models.Attachment
+	if err := s.Scan(&a.ID, &a.LedgerID, &a.RecordID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256,
+		&a.StorageKey, &a.ThumbKey, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	a.HasThumbnail = a.ThumbKey != ""
+	return &a, nil
+}
+
+// ListAttachments 记录的附件，按上传顺序
+func (db *DB) ListAttachments(ledgerID, recordID int64) ([]*models.Attachment, error) {
+	rows, err := db.conn.Query(`SELECT `+attachmentColumns+` FROM attachments WHERE ledger_id = ? AND record_id = ? ORDER BY id`, ledgerID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// GetAttachment 获取记录的附件，不存在或不属于该记录时返回 nil
+func (db *DB) GetAttachment(ledgerID, recordID, id int64) (*models.Attachment, error) {
+	a, err := scanAttachment(db.conn.QueryRow(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ? AND ledger_id = ? AND record_id = ?`, id, ledgerID, recordID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// CountAttachments 记录的附件数
+func (db *DB) CountAttachments(recordID int64) (int, error) {
+	var n int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM attachments WHERE record_id = ?`, recordID).Scan(&n)
+	return n, err
+}
+
+// CreateAttachment 写入附件信息（文件须已保存到存储后端）
+func (db *DB) CreateAttachment(a *models.Attachment) error {
+	res, err := db.conn.Exec(
+		`INSERT INTO attachments (ledger_id, record_id, user_id, filename, content_type, size, sha256, storage_key, thumb_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.LedgerID, a.RecordID, a.UserID, a.Filename, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.ThumbKey,
+	)
+	if err != nil {
+		return err
+	}
+	a.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// DeleteAttachment 删除附件信息，返回其存储 key 供调用方删除文件
+func (db *DB) DeleteAttachment(ledgerID, recordID, id int64) ([]string, error) {
+	a, err := db.GetAttachment(ledgerID, recordID, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, sql.ErrNoRows
+	}
+	if _, err := db.conn.Exec(`DELETE FROM attachments WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return attachmentKeys(a.StorageKey, a.ThumbKey), nil
+}
+
+func attachmentKeys(storageKey, thumbKey string) []string {
+	if thumbKey == "" {
+		return []string{storageKey}
+	}
+	return []string{storageKey, thumbKey}
+}
+
+// purgeAttachments 删除满足 cond 的记录的附件信息，返回其存储 key
+func purgeAttachments(q querier, cond string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(`SELECT storage_key, thumb_key FROM attachments WHERE record_id IN (SELECT id FROM records WHERE `+cond+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key, thumb string
+		if err := rows.Scan(&key, &thumb); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, attachmentKeys(key, thumb)...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = q.Exec(`DELETE FROM attachments WHERE record_id IN (SELECT id FROM records WHERE `+cond+`)`, args...)
+	return keys, err
+}
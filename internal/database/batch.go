@@ -0,0 +1,58 @@
+package database
+
+import (
+	"account-service/internal/models"
+)
+
+// 批量操作类型
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOp 已校验的批量操作项。CategoryName 非空时在事务内按名称查找或以 CategoryType 创建分类后关联
+type BatchOp struct {
+	Op           string
+	ID           int64                       // update/delete 的记录 ID，create 成功后回填
+	Record       *models.Record              // create
+	Patch        *models.UpdateRecordRequest // update
+	CategoryName string
+	CategoryType string
+}
+
+// Batch 以 userID 为操作者在同一事务中依次执行批量操作，任一项失败则全部回滚，返回失败项的下标与错误
+func (db *DB) Batch(ledgerID, userID int64, ops []*BatchOp) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	for i, op := range ops {
+		if op.CategoryName != "" {
+			cat, err := findOrCreateCategory(tx, ledgerID, op.CategoryName, op.CategoryType)
+			if err != nil {
+				return i, err
+			}
+			if op.Record != nil {
+				op.Record.CategoryID, op.Record.Category = &cat.ID, cat.Name
+			}
+			if op.Patch != nil {
+				op.Patch.CategoryID, op.Patch.Category = &cat.ID, &cat.Name
+			}
+		}
+		switch op.Op {
+		case BatchCreate:
+			err = createRecord(tx, op.Record)
+			op.ID = op.Record.ID
+		case BatchUpdate:
+			_, err = updateRecord(tx, ledgerID, op.ID, 0, op.Patch, revisionMeta{UserID: userID, Action: models.RevisionUpdate})
+		case BatchDelete:
+			err = deleteRecord(tx, ledgerID, op.ID, userID, 0)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return -1, tx.Commit()
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// TestBatchRollback 任一项失败时此前已执行的新增、修改、删除及新建的分类全部回滚
+func TestBatchRollback(t *testing.T) {
+	db := newTestDB(t)
+	kept := &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-01", Amount: -100, Currency: "CNY", Description: "lunch"}
+	doomed := &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-02", Amount: -200, Currency: "CNY", Description: "dinner"}
+	for _, r := range []*models.Record{kept, doomed} {
+		if err := db.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := db.GetByID(1, kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := "changed"
+	ops := []*BatchOp{
+		{Op: BatchCreate, Record: &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-03", Amount: -300, Currency: "CNY", Description: "new"},
+			CategoryName: "批量分类", CategoryType: models.CategoryTypeExpense},
+		{Op: BatchUpdate, ID: kept.ID, Patch: &models.UpdateRecordRequest{Description: &desc}},
+		{Op: BatchDelete, ID: doomed.ID},
+		{Op: BatchDelete, ID: 999},
+	}
+	i, err := db.Batch(1, 1, ops)
+	if i != 3 || !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Batch = (%d, %v), want (3, sql.ErrNoRows)", i, err)
+	}
+
+	got, err := db.GetByID(1, kept.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID(%d) = %v, %v", kept.ID, got, err)
+	}
+	if got.Description != "lunch" || got.Version != before.Version {
+		t.Errorf("updated record = {%q, v%d}, want {%q, v%d}", got.Description, got.Version, "lunch", before.Version)
+	}
+	if got, err := db.GetByID(1, doomed.ID); err != nil || got == nil {
+		t.Errorf("deleted record not restored: %v, %v", got, err)
+	}
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM records`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("records = %d, want 2", n)
+	}
+	if cat, err := db.FindCategory(1, "批量分类"); err != nil || cat != nil {
+		t.Errorf("category created in rolled back batch: %v, %v", cat, err)
+	}
+	for _, id := range []int64{kept.ID, doomed.ID} {
+		revs, err := db.ListRevisions(1, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revs) != 1 {
+			t.Errorf("record %d has %d revisions, want 1", id, len(revs))
+		}
+	}
+}
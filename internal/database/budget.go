@@ -0,0 +1,227 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"math"
+	"time"
+)
+
+func (db *DB) migrateBudgets() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS budgets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			category_id INTEGER,
+			period TEXT NOT NULL,
+			amount INTEGER NOT NULL, -- 单位：分
+			currency TEXT NOT NULL DEFAULT 'CNY',
+			rollover INTEGER NOT NULL DEFAULT 0,
+			start_date TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_scope ON budgets(ledger_id, COALESCE(category_id, 0), period);
+	`)
+	return err
+}
+
+const budgetSelect = `
+	SELECT b.id, b.ledger_id, b.category_id, COALESCE(c.name, ''), b.period, b.amount, b.currency, b.rollover,
+		b.start_date, b.created_at, b.updated_at
+	FROM budgets b LEFT JOIN categories c ON c.id = b.category_id`
+
+func scanBudget(s rowScanner) (*models.Budget, error) {
+	var b models.Budget
+	var categoryID sql.NullInt64
+	if err := s.Scan(&b.ID, &b.LedgerID, &categoryID, &b.Category, &b.Period, &b.Amount, &b.Currency, &b.Rollover,
+		&b.StartDate, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		b.CategoryID = &categoryID.Int64
+	}
+	return &b, nil
+}
+
+// ListBudgets 账本内的预算，总预算在前
+func (db *DB) ListBudgets(ledgerID int64) ([]*models.Budget, error) {
+	rows, err := db.conn.Query(budgetSelect+` WHERE b.ledger_id = ? ORDER BY b.category_id IS NOT NULL, b.period, b.id`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Budget
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+// GetBudget 获取账本内的预算，不存在时返回 nil
+func (db *DB) GetBudget(ledgerID, id int64) (*models.Budget, error) {
+	b, err := scanBudget(db.conn.QueryRow(budgetSelect+` WHERE b.id = ? AND b.ledger_id = ?`, id, ledgerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+// CreateBudget 创建预算，起始日对齐到按 cal 划分的所在周期的起始日
+func (db *DB) CreateBudget(b *models.Budget, cal models.Calendar) error {
+	start, err := budgetPeriodStart(cal, b.Period, b.StartDate)
+	if err != nil {
+		return err
+	}
+	b.StartDate = start
+	res, err := db.conn.Exec(
+		`INSERT INTO budgets (ledger_id, category_id, period, amount, currency, rollover, start_date) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		b.LedgerID, nullID(b.CategoryID), b.Period, b.Amount, b.Currency, b.Rollover, b.StartDate,
+	)
+	if err != nil {
+		return err
+	}
+	b.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// UpdateBudget 更新额度、结转与起始日（周期与范围不可改）
+func (db *DB) UpdateBudget(ledgerID int64, b *models.Budget, cal models.Calendar) error {
+	start, err := budgetPeriodStart(cal, b.Period, b.StartDate)
+	if err != nil {
+		return err
+	}
+	b.StartDate = start
+	res, err := db.conn.Exec(
+		`UPDATE budgets SET amount=?, rollover=?, start_date=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND ledger_id=?`,
+		b.Amount, b.Rollover, b.StartDate, b.ID, ledgerID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteBudget 删除预算
+func (db *DB) DeleteBudget(ledgerID, id int64) error {
+	res, err := db.conn.Exec(`DELETE FROM budgets WHERE id=? AND ledger_id=?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// BudgetStatus 各预算截至 date 所在周期的执行情况，periods 为返回的周期数（含当前周期）。
+// 周期按查看者的 cal 划分（预算起始日按 cal 重新对齐）。
+// 支出沿用汇总的统计口径（排除转账、按预算币种换算），结转需从预算首个周期逐期累计
+func (db *DB) BudgetStatus(ledgerID int64, date string, periods int, cal models.Calendar) ([]*models.BudgetStatus, error) {
+	budgets, err := db.ListBudgets(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	list := []*models.BudgetStatus{}
+	for _, b := range budgets {
+		st := &models.BudgetStatus{Budget: b, Periods: []*models.BudgetPeriodStatus{}}
+		list = append(list, st)
+		cur, err := budgetPeriodStart(cal, b.Period, date)
+		if err != nil {
+			return nil, err
+		}
+		first, err := budgetPeriodStart(cal, b.Period, b.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		if first > cur {
+			continue
+		}
+		cond, condArgs := "", []interface{}{}
+		if b.CategoryID != nil {
+			cond, condArgs = "c.id = ? OR c.parent_id = ?", []interface{}{*b.CategoryID, *b.CategoryID}
+		}
+		end := budgetPeriodEnd(b.Period, cur)
+		cte, args := statsCTE(ledgerID, first, end, b.Currency, cond, condArgs...)
+		items, err := db.periodBreakdown(cte, args, budgetGroupBy(b.Period), cal, first, end)
+		if err != nil {
+			return nil, err
+		}
+		spent := map[string]models.Money{}
+		for _, it := range items {
+			spent[it.Period] = it.Expense
+		}
+		var carry models.Money
+		for start := first; start <= cur; start = nextBudgetPeriod(b.Period, start) {
+			p := &models.BudgetPeriodStatus{
+				Period:    budgetPeriodKey(cal, b.Period, start),
+				StartDate: start,
+				EndDate:   budgetPeriodEnd(b.Period, start),
+				Budget:    b.Amount,
+				Carryover: carry,
+			}
+			p.Available = p.Budget + p.Carryover
+			p.Spent = spent[p.Period]
+			p.Remaining = p.Available - p.Spent
+			p.Over = p.Remaining < 0
+			switch {
+			case p.Available > 0:
+				p.PercentUsed = math.Round(float64(p.Spent)/float64(p.Available)*1000) / 10
+			case p.Spent > 0:
+				p.PercentUsed = 100
+			}
+			carry = 0
+			if b.Rollover && p.Remaining > 0 {
+				carry = p.Remaining
+			}
+			st.Periods = append(st.Periods, p)
+		}
+		if n := len(st.Periods); n > periods {
+			st.Periods = st.Periods[n-periods:]
+		}
+		if n := len(st.Periods); n > 0 {
+			st.Current = st.Periods[n-1]
+		}
+	}
+	return list, nil
+}
+
+// budgetPeriodStart date 所在预算周期（按 cal 划分的月或财年）的起始日
+func budgetPeriodStart(cal models.Calendar, period, date string) (string, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", err
+	}
+	return periodStart(budgetGroupBy(period), cal, t).Format("2006-01-02"), nil
+}
+
+// budgetPeriodEnd 起始日为 start 的预算周期的最后一天
+func budgetPeriodEnd(period, start string) string {
+	t, _ := time.Parse("2006-01-02", start)
+	return periodLastDay(budgetGroupBy(period), t).Format("2006-01-02")
+}
+
+func nextBudgetPeriod(period, start string) string {
+	t, _ := time.Parse("2006-01-02", start)
+	return periodLastDay(budgetGroupBy(period), t).AddDate(0, 0, 1).Format("2006-01-02")
+}
+
+func budgetPeriodKey(cal models.Calendar, period, start string) string {
+	t, _ := time.Parse("2006-01-02", start)
+	return periodLabel(budgetGroupBy(period), cal, t)
+}
+
+// budgetGroupBy 预算周期对应的汇总分组，分项标记与 budgetPeriodKey 一致
+func budgetGroupBy(period string) string {
+	if period == models.BudgetPeriodYearly {
+		return models.GroupByYear
+	}
+	return models.GroupByMonth
+}
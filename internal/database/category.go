@@ -0,0 +1,249 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrCategoryInUse 分类下仍有记录，删除时须指定合并到的分类
+var ErrCategoryInUse = errors.New("分类下仍有记录，请指定 merge_into 合并到其他分类")
+
+// ErrCategoryHasChildren 分类下仍有子分类
+var ErrCategoryHasChildren = errors.New("请先删除或移走子分类")
+
+func (db *DB) migrateCategories() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			parent_id INTEGER,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'expense',
+			icon TEXT NOT NULL DEFAULT '',
+			color TEXT NOT NULL DEFAULT '',
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories(ledger_id, COALESCE(parent_id, 0), name);
+		CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN category_id INTEGER`)
+	_, _ = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_category_id ON records(category_id)`)
+	return nil
+}
+
+// backfillCategories 将尚未关联分类的记录按去除首尾空白后的分类名建为一级分类并关联，
+// 类型按该分类下金额合计的正负推断。转账分录不参与。
+func (db *DB) backfillCategories() error {
+	const pending = `category_id IS NULL AND transfer_id IS NULL AND ledger_id IS NOT NULL AND TRIM(COALESCE(category, '')) <> ''`
+	if _, err := db.conn.Exec(`
+		INSERT INTO categories (ledger_id, name, type)
+		SELECT ledger_id, TRIM(category), CASE WHEN SUM(amount) >= 0 THEN 'income' ELSE 'expense' END
+		FROM records WHERE ` + pending + `
+		GROUP BY ledger_id, TRIM(category)
+		ON CONFLICT DO NOTHING
+	`); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`
+		UPDATE records SET category = TRIM(category), category_id = (
+			SELECT c.id FROM categories c
+			WHERE c.ledger_id = records.ledger_id AND c.parent_id IS NULL AND c.name = TRIM(records.category)
+		)
+		WHERE ` + pending)
+	return err
+}
+
+const categoryColumns = `id, ledger_id, parent_id, name, type, icon, color, sort_order, created_at, updated_at`
+
+func scanCategory(s rowScanner) (*models.Category, error) {
+	var c models.Category
+	var parentID sql.NullInt64
+	if err := s.Scan(&c.ID, &c.LedgerID, &parentID, &c.Name, &c.Type, &c.Icon, &c.Color, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		c.ParentID = &parentID.Int64
+	}
+	return &c, nil
+}
+
+// ListCategories 账本内的分类树（一级分类含 Children），typ 非空时按类型筛选
+func (db *DB) ListCategories(ledgerID int64, typ string) ([]*models.Category, error) {
+	where := `ledger_id = ?`
+	args := []interface{}{ledgerID}
+	if typ != "" {
+		where += ` AND type = ?`
+		args = append(args, typ)
+	}
+	rows, err := db.conn.Query(`SELECT `+categoryColumns+` FROM categories WHERE `+where+
+		` ORDER BY parent_id IS NOT NULL, sort_order, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Category
+	byID := map[int64]*models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ParentID == nil {
+			list = append(list, c)
+			byID[c.ID] = c
+		} else if p, ok := byID[*c.ParentID]; ok {
+			p.Children = append(p.Children, c)
+		}
+	}
+	return list, nil
+}
+
+// GetCategory 获取账本内的分类，不存在时返回 nil
+func (db *DB) GetCategory(ledgerID, id int64) (*models.Category, error) {
+	c, err := scanCategory(db.conn.QueryRow(`SELECT `+categoryColumns+` FROM categories WHERE id = ? AND ledger_id = ?`, id, ledgerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// FindCategory 按名称查找分类（优先一级分类），不存在时返回 nil
+func (db *DB) FindCategory(ledgerID int64, name string) (*models.Category, error) {
+	return findCategory(db.conn, ledgerID, name)
+}
+
+func findCategory(q querier, ledgerID int64, name string) (*models.Category, error) {
+	c, err := scanCategory(q.QueryRow(`SELECT `+categoryColumns+` FROM categories
+		WHERE ledger_id = ? AND name = ? ORDER BY parent_id IS NOT NULL, id LIMIT 1`, ledgerID, strings.TrimSpace(name)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// FindOrCreateCategory 按名称查找分类（优先一级分类），不存在时以 typ 创建一级分类
+func (db *DB) FindOrCreateCategory(ledgerID int64, name, typ string) (*models.Category, error) {
+	return findOrCreateCategory(db.conn, ledgerID, name, typ)
+}
+
+func findOrCreateCategory(q querier, ledgerID int64, name, typ string) (*models.Category, error) {
+	c, err := findCategory(q, ledgerID, name)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = &models.Category{LedgerID: ledgerID, Name: strings.TrimSpace(name), Type: typ}
+	if err := createCategory(q, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (db *DB) CreateCategory(c *models.Category) error {
+	return createCategory(db.conn, c)
+}
+
+func createCategory(q querier, c *models.Category) error {
+	res, err := q.Exec(
+		`INSERT INTO categories (ledger_id, parent_id, name, type, icon, color, sort_order) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.LedgerID, nullID(c.ParentID), c.Name, c.Type, c.Icon, c.Color, c.SortOrder,
+	)
+	if err != nil {
+		return err
+	}
+	c.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// UpdateCategory 更新分类；改名时同步记录上的分类名，一级分类改类型时子分类随之变更
+func (db *DB) UpdateCategory(ledgerID int64, c *models.Category) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(
+		`UPDATE categories SET parent_id=?, name=?, type=?, icon=?, color=?, sort_order=?, updated_at=CURRENT_TIMESTAMP
+		 WHERE id=? AND ledger_id=?`,
+		nullID(c.ParentID), c.Name, c.Type, c.Icon, c.Color, c.SortOrder, c.ID, ledgerID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`UPDATE categories SET type=? WHERE parent_id=?`, c.Type, c.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE records SET category=?, version=version+1 WHERE category_id=?`, c.Name, c.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE records SET version=version+1 WHERE id IN (SELECT record_id FROM record_splits WHERE category_id=?)`, c.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE record_splits SET category=? WHERE category_id=?`, c.Name, c.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteCategory 删除分类及其预算。分类下有记录或拆分行（不含回收站）时须指定 mergeInto，记录与拆分行将改挂到该分类；
+// 有子分类时返回 ErrCategoryHasChildren
+func (db *DB) DeleteCategory(ledgerID, id int64, mergeInto *models.Category) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var children, records int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM categories WHERE parent_id = ?`, id).Scan(&children); err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrCategoryHasChildren
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM records WHERE deleted_at IS NULL AND (category_id = ?
+		OR id IN (SELECT record_id FROM record_splits WHERE category_id = ?))`, id, id).Scan(&records); err != nil {
+		return err
+	}
+	if records > 0 && mergeInto == nil {
+		return ErrCategoryInUse
+	}
+	if _, err := tx.Exec(`UPDATE records SET version=version+1 WHERE id IN (SELECT record_id FROM record_splits WHERE category_id=?)`, id); err != nil {
+		return err
+	}
+	if mergeInto != nil {
+		_, err = tx.Exec(`UPDATE records SET category_id=?, category=?, version=version+1, updated_at=CURRENT_TIMESTAMP WHERE category_id=?`,
+			mergeInto.ID, mergeInto.Name, id)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE record_splits SET category_id=?, category=? WHERE category_id=?`, mergeInto.ID, mergeInto.Name, id)
+		}
+	} else {
+		// 仅回收站中的记录仍引用该分类：解除关联，保留分类名称
+		_, err = tx.Exec(`UPDATE records SET category_id=NULL, version=version+1 WHERE category_id=?`, id)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE record_splits SET category_id=NULL WHERE category_id=?`, id)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM categories WHERE id=? AND ledger_id=?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM budgets WHERE category_id=?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"sort"
+	"time"
+)
+
+// compareReport 统计 q.Compare 指定的对比区间（筛选条件与报表相同），计算收支与各分类的变化
+func (db *DB) compareReport(r *models.Report, ledgerID int64, q models.ReportQuery, base, cond string, condArgs []interface{}) (*models.ReportComparison, error) {
+	start, err := time.Parse("2006-01-02", q.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse("2006-01-02", q.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	cs, ce := q.Calendar.CompareRange(q.Compare, start, end)
+	cmp := &models.ReportComparison{
+		Mode:      q.Compare,
+		StartDate: cs.Format("2006-01-02"),
+		EndDate:   ce.Format("2006-01-02"),
+		Threshold: q.Threshold,
+	}
+	cte, args := statsCTE(ledgerID, cmp.StartDate, cmp.EndDate, base, cond, condArgs...)
+	s, err := db.summarize(cte, args, base)
+	if err != nil {
+		return nil, err
+	}
+	cmp.Income, cmp.Expense, cmp.Balance, cmp.Count = s.Income, s.Expense, s.Balance, s.Count
+	cmp.Delta = models.ReportDelta{
+		Income:  models.NewAmountDelta(r.Income, s.Income),
+		Expense: models.NewAmountDelta(r.Expense, s.Expense),
+		Balance: models.NewAmountDelta(r.Balance, s.Balance),
+	}
+	prev, err := db.categoryBreakdown(cte, args, q.CategoryID == 0)
+	if err != nil {
+		return nil, err
+	}
+	cmp.ByCategory = categoryDeltas(r.ByCategory, prev, q.Threshold)
+	return cmp, nil
+}
+
+// categoryDeltas 按分类 ID 对齐两个区间的分类统计（未分类视为同一项），金额取合计的绝对值
+func categoryDeltas(cur, prev []*models.CategoryItem, threshold float64) []*models.CategoryDelta {
+	key := func(id *int64) int64 {
+		if id == nil {
+			return 0
+		}
+		return *id
+	}
+	previous := map[int64]*models.CategoryItem{}
+	for _, it := range prev {
+		previous[key(it.CategoryID)] = it
+	}
+	list := []*models.CategoryDelta{}
+	add := func(it *models.CategoryItem, current, before models.Money) {
+		d := &models.CategoryDelta{CategoryID: it.CategoryID, Category: it.Category, AmountDelta: models.NewAmountDelta(current, before)}
+		d.Flagged = d.Change > 0 && (d.Percent == nil || *d.Percent > threshold)
+		list = append(list, d)
+	}
+	for _, it := range cur {
+		var before models.Money
+		if p, ok := previous[key(it.CategoryID)]; ok {
+			before = p.Total.Abs()
+			delete(previous, key(it.CategoryID))
+		}
+		add(it, it.Total.Abs(), before)
+	}
+	// 仅在对比区间出现的分类
+	for _, it := range prev {
+		if _, ok := previous[key(it.CategoryID)]; ok {
+			add(it, 0, it.Total.Abs())
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Change.Abs() > list[j].Change.Abs() })
+	return list
+}
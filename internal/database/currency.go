@@ -0,0 +1,111 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+)
+
+func (db *DB) migrateCurrency() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			from_currency TEXT NOT NULL,
+			to_currency TEXT NOT NULL,
+			rate REAL NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (from_currency, to_currency, date)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN currency TEXT`)
+	// 旧记录币种：有账户取账户币种，否则为默认币种
+	_, err = db.conn.Exec(`
+		UPDATE records SET currency = COALESCE((SELECT a.currency FROM accounts a WHERE a.id = records.account_id), ?)
+		WHERE currency IS NULL
+	`, models.DefaultCurrency)
+	return err
+}
+
+// SaveExchangeRates 批量写入汇率，同一日期同一币种对已存在时覆盖
+func (db *DB) SaveExchangeRates(rates []models.ExchangeRateInput) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range rates {
+		if _, err := tx.Exec(`
+			INSERT INTO exchange_rates (date, from_currency, to_currency, rate) VALUES (?, ?, ?, ?)
+			ON CONFLICT (from_currency, to_currency, date) DO UPDATE SET rate = excluded.rate
+		`, r.Date, r.FromCurrency, r.ToCurrency, r.Rate); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListExchangeRates 按币种与日期筛选汇率
+func (db *DB) ListExchangeRates(q *models.ExchangeRateQuery) ([]*models.ExchangeRate, error) {
+	where := "1=1"
+	var args []interface{}
+	if q.FromCurrency != "" {
+		where += " AND from_currency = ?"
+		args = append(args, q.FromCurrency)
+	}
+	if q.ToCurrency != "" {
+		where += " AND to_currency = ?"
+		args = append(args, q.ToCurrency)
+	}
+	if q.StartDate != "" {
+		where += " AND date >= ?"
+		args = append(args, q.StartDate)
+	}
+	if q.EndDate != "" {
+		where += " AND date <= ?"
+		args = append(args, q.EndDate)
+	}
+	rows, err := db.conn.Query(`SELECT id, date, from_currency, to_currency, rate, created_at FROM exchange_rates WHERE `+where+
+		` ORDER BY date DESC, from_currency, to_currency LIMIT 1000`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.ExchangeRate
+	for rows.Next() {
+		var r models.ExchangeRate
+		if err := rows.Scan(&r.ID, &r.Date, &r.FromCurrency, &r.ToCurrency, &r.Rate, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &r)
+	}
+	return list, nil
+}
+
+// DeleteExchangeRate 删除一条汇率
+func (db *DB) DeleteExchangeRate(id int64) error {
+	res, err := db.conn.Exec(`DELETE FROM exchange_rates WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ConvertAmount 按 date 的汇率（取法同汇总）将 amount 从 currency 换算为 base，无可用汇率时 ok 为 false
+func (db *DB) ConvertAmount(amount models.Money, currency, base, date string) (models.Money, bool, error) {
+	if currency == base {
+		return amount, true, nil
+	}
+	var converted sql.NullInt64
+	err := db.conn.QueryRow(`SELECT CAST(ROUND(? * `+rateExpr+`) AS INTEGER) FROM (SELECT ? AS currency, ? AS date) r`,
+		amount, base, base, base, base, currency, date).Scan(&converted)
+	if err != nil {
+		return 0, false, err
+	}
+	return models.Money(converted.Int64), converted.Valid, nil
+}
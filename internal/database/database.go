@@ -3,8 +3,11 @@ package database
 import (
 	"account-service/internal/models"
 	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -18,10 +21,20 @@ func New(dbPath string) (*DB, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
-	conn, err := sql.Open("sqlite", dbPath)
+	// 调度器与请求并发写入：busy_timeout 使写入排队等待而非立即返回 SQLITE_BUSY，
+	// 事务以 IMMEDIATE 开始，避免读事务升级为写事务时因快照过期而无法等待
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	conn, err := sql.Open("sqlite", dbPath+sep+"_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
+	// WAL 允许读写并发；该模式持久保存在库文件中，只需设置一次（逐连接设置会在并发建连时争用锁）
+	if _, err := conn.Exec(`PRAGMA journal_mode=WAL`); err != nil {
+		return nil, err
+	}
 	db := &DB{conn: conn}
 	if err := db.migrate(); err != nil {
 		return nil, err
@@ -34,7 +47,7 @@ func (db *DB) migrate() error {
 	CREATE TABLE IF NOT EXISTS records (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		date TEXT NOT NULL,
-		amount REAL NOT NULL,
+		amount INTEGER NOT NULL, -- 金额，单位：分
 		category TEXT,
 		description TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -52,17 +65,287 @@ func (db *DB) migrate() error {
 	// 添加 user_id 列（兼容旧库，忽略已存在错误）
 	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN user_id INTEGER`)
 	_, _ = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_user ON records(user_id)`)
+	if err := db.migrateLedgers(); err != nil {
+		return err
+	}
+	if err := db.migrateAccounts(); err != nil {
+		return err
+	}
+	if err := db.migrateTransfers(); err != nil {
+		return err
+	}
+	if err := db.migrateMoneyColumns(); err != nil {
+		return err
+	}
+	if err := db.migrateCurrency(); err != nil {
+		return err
+	}
+	if err := db.migrateCategories(); err != nil {
+		return err
+	}
+	if err := db.migrateTags(); err != nil {
+		return err
+	}
+	if err := db.migrateBudgets(); err != nil {
+		return err
+	}
+	if err := db.migrateRecurring(); err != nil {
+		return err
+	}
+	if err := db.migrateSearch(); err != nil {
+		return err
+	}
+	if err := db.migrateTrash(); err != nil {
+		return err
+	}
+	if err := db.migrateRevisions(); err != nil {
+		return err
+	}
+	// 版本号用于乐观并发控制，每次修改记录时递增
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN version INTEGER NOT NULL DEFAULT 1`)
+	if err := db.migrateIdempotency(); err != nil {
+		return err
+	}
+	if err := db.migrateAttachments(); err != nil {
+		return err
+	}
+	if err := db.migrateSplits(); err != nil {
+		return err
+	}
+	if err := db.migratePayees(); err != nil {
+		return err
+	}
+	return db.BackfillRecordOwner()
+}
+
+// migrateMoneyColumns 将旧库中 REAL 类型的金额列（元）无损转换为 INTEGER（分）。
+// SQLite 无法修改列类型，因此重建表并按原 ID 复制数据，随后恢复索引与自增序列。
+func (db *DB) migrateMoneyColumns() error {
+	recordsReal, err := db.columnType("records", "amount")
+	if err != nil {
+		return err
+	}
+	accountsReal, err := db.columnType("accounts", "opening_balance")
+	if err != nil {
+		return err
+	}
+	if recordsReal != "REAL" && accountsReal != "REAL" {
+		return nil
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if recordsReal == "REAL" {
+		if err := rebuildTable(tx, "records", `
+			CREATE TABLE records_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				date TEXT NOT NULL,
+				amount INTEGER NOT NULL,
+				category TEXT,
+				description TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				user_id INTEGER,
+				ledger_id INTEGER,
+				account_id INTEGER,
+				transfer_id INTEGER
+			)`, `
+			INSERT INTO records_new (id, date, amount, category, description, created_at, updated_at, user_id, ledger_id, account_id, transfer_id)
+			SELECT id, date, CAST(ROUND(amount * 100) AS INTEGER), category, description, created_at, updated_at, user_id, ledger_id, account_id, transfer_id
+			FROM records`,
+			`CREATE INDEX idx_records_date ON records(date)`,
+			`CREATE INDEX idx_records_category ON records(category)`,
+			`CREATE INDEX idx_records_user ON records(user_id)`,
+			`CREATE INDEX idx_records_ledger ON records(ledger_id, date)`,
+			`CREATE INDEX idx_records_account ON records(account_id, date)`,
+			`CREATE INDEX idx_records_transfer ON records(transfer_id)`,
+		); err != nil {
+			return err
+		}
+	}
+	if accountsReal == "REAL" {
+		if err := rebuildTable(tx, "accounts", `
+			CREATE TABLE accounts_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				ledger_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				type TEXT NOT NULL DEFAULT 'other',
+				opening_balance INTEGER NOT NULL DEFAULT 0,
+				currency TEXT NOT NULL DEFAULT 'CNY',
+				archived INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`, `
+			INSERT INTO accounts_new (id, ledger_id, name, type, opening_balance, currency, archived, created_at, updated_at)
+			SELECT id, ledger_id, name, type, CAST(ROUND(opening_balance * 100) AS INTEGER), currency, archived, created_at, updated_at
+			FROM accounts`,
+			`CREATE INDEX idx_accounts_ledger ON accounts(ledger_id)`,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// columnType 返回表中某列声明的类型（大写），列不存在时返回空字符串
+func (db *DB) columnType(table, column string) (string, error) {
+	rows, err := db.conn.Query(`SELECT name, type FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return "", err
+		}
+		if name == column {
+			return strings.ToUpper(typ), nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// rebuildTable 用 createNew 建立 <table>_new，执行 copy 复制数据后替换原表，并创建索引、保留自增序列
+func rebuildTable(tx *sql.Tx, table, createNew, copy string, indexes ...string) error {
+	var seq sql.NullInt64
+	_ = tx.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name = ?`, table).Scan(&seq)
+	stmts := []string{createNew, copy, `DROP TABLE ` + table, `ALTER TABLE ` + table + `_new RENAME TO ` + table}
+	for _, stmt := range append(stmts, indexes...) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if seq.Valid {
+		if _, err := tx.Exec(`UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = ?`, seq.Int64, table); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// BackfillRecordOwner 将无归属（user_id 为空）的旧记录归属给首个管理员，无管理员时不做处理；
+// 并把尚未归入账本的记录放入其所属用户的默认账本
+func (db *DB) BackfillRecordOwner() error {
+	_, err := db.conn.Exec(`
+		UPDATE records SET user_id = (SELECT MIN(id) FROM users WHERE role = 'admin')
+		WHERE user_id IS NULL AND EXISTS (SELECT 1 FROM users WHERE role = 'admin')
+	`)
+	if err != nil {
+		return err
+	}
+	if err := db.backfillRecordLedgers(); err != nil {
+		return err
+	}
+	if err := db.backfillCategories(); err != nil {
+		return err
+	}
+	return db.backfillPayees()
+}
+
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
+// recordColumns 记录查询列，与 scanRecord 的扫描顺序一致
+const recordColumns = `id, ledger_id, user_id, account_id, transfer_id, recurring_rule_id, date, amount, COALESCE(currency,'CNY'), category_id, COALESCE(category,''), COALESCE(description,''), COALESCE(payee,''), payee_id, version, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier 由 *sql.DB 与 *sql.Tx 共同实现，便于同一逻辑在事务内外复用
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanRecord(s rowScanner) (*models.Record, error) {
+	var r models.Record
+	var accountID, transferID, recurringRuleID, categoryID, payeeID sql.NullInt64
+	if err := s.Scan(&r.ID, &r.LedgerID, &r.UserID, &accountID, &transferID, &recurringRuleID, &r.Date, &r.Amount, &r.Currency, &categoryID, &r.Category, &r.Description, &r.Payee, &payeeID, &r.Version, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if accountID.Valid {
+		r.AccountID = &accountID.Int64
+	}
+	if transferID.Valid {
+		r.TransferID = &transferID.Int64
+	}
+	if recurringRuleID.Valid {
+		r.RecurringRuleID = &recurringRuleID.Int64
+	}
+	if categoryID.Valid {
+		r.CategoryID = &categoryID.Int64
+	}
+	if payeeID.Valid {
+		r.PayeeID = &payeeID.Int64
+	}
+	return &r, nil
+}
+
+// nullID 将可选 ID 转为数据库值，nil 或 0 表示空
+func nullID(id *int64) sql.NullInt64 {
+	if id == nil || *id == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *id, Valid: true}
+}
+
+// Create 在同一事务中写入记录及其标签
 func (db *DB) Create(r *models.Record) error {
-	res, err := db.conn.Exec(
-		`INSERT INTO records (date, amount, category, description) VALUES (?, ?, ?, ?)`,
-		r.Date, r.Amount, r.Category, r.Description,
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := createRecord(tx, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createRecord 写入记录及其标签、拆分行，并以创建者为操作者写入 create 修订；交易对方按名称匹配或自动创建
+func createRecord(q querier, r *models.Record) error {
+	payeeID, payee, err := linkPayee(q, r.LedgerID, r.PayeeID, r.Payee)
+	if err != nil {
+		return err
+	}
+	r.PayeeID, r.Payee = payeeID, payee
+	if err := insertRecord(q, r); err != nil {
+		return err
+	}
+	if err := setRecordTags(q, r.LedgerID, r.ID, r.Tags); err != nil {
+		return err
+	}
+	if len(r.Splits) > 0 {
+		if err := setRecordSplits(q, r.LedgerID, r.ID, r.Splits); err != nil {
+			return err
+		}
+		if err := checkSplits(q, r.ID); err != nil {
+			return err
+		}
+	}
+	return writeCreateRevision(q, r)
+}
+
+// writeCreateRevision 以记录创建者为操作者写入 create 修订
+func writeCreateRevision(q querier, r *models.Record) error {
+	after, err := snapshotRecord(q, r.LedgerID, r.ID)
+	if err != nil {
+		return err
+	}
+	_, err = writeRevision(q, r.LedgerID, r.ID, revisionMeta{UserID: r.UserID, Action: models.RevisionCreate}, nil, after)
+	return err
+}
+
+func insertRecord(q querier, r *models.Record) error {
+	res, err := q.Exec(
+		`INSERT INTO records (ledger_id, user_id, account_id, transfer_id, recurring_rule_id, date, amount, currency, category_id, category, description, payee, payee_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.LedgerID, r.UserID, nullID(r.AccountID), nullID(r.TransferID), nullID(r.RecurringRuleID), r.Date, r.Amount, r.Currency, nullID(r.CategoryID), r.Category, r.Description, r.Payee, nullID(r.PayeeID),
 	)
 	if err != nil {
 		return err
@@ -72,27 +355,147 @@ func (db *DB) Create(r *models.Record) error {
 	return nil
 }
 
-func (db *DB) GetByID(id int64) (*models.Record, error) {
-	var r models.Record
-	err := db.conn.QueryRow(
-		`SELECT id, date, amount, category, description, created_at, updated_at 
-		 FROM records WHERE id = ?`, id,
-	).Scan(&r.ID, &r.Date, &r.Amount, &r.Category, &r.Description, &r.CreatedAt, &r.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
+// GetByID 获取账本内的单条记录，不存在或不属于该账本时返回 nil
+func (db *DB) GetByID(ledgerID, id int64) (*models.Record, error) {
+	r, err := getRecord(db.conn, ledgerID, id)
+	if err != nil || r == nil {
+		return nil, err
 	}
-	if err != nil {
+	if err := db.attachTags([]*models.Record{r}); err != nil {
 		return nil, err
 	}
-	return &r, nil
+	if err := db.attachSplits([]*models.Record{r}); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
-func (db *DB) List(params *models.QueryParams) ([]*models.Record, int64, error) {
+// getRecord 获取记录（不含标签），不存在时返回 nil
+func getRecord(q querier, ledgerID, id int64) (*models.Record, error) {
+	r, err := scanRecord(q.QueryRow(`SELECT `+recordColumns+` FROM records WHERE id = ? AND ledger_id = ? AND deleted_at IS NULL`, id, ledgerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// recordSortColumns 记录列表排序字段到排序表达式的映射。
+// 可为空的列须与游标值（扫描时已 COALESCE）一致，否则 NULL 行在游标比较中被跳过
+var recordSortColumns = map[string]string{
+	"date":       "date",
+	"amount":     "amount",
+	"category":   "COALESCE(category, '')",
+	"created_at": "datetime(created_at)",
+}
+
+// List 查询记录。分页模式按 page/page_size 偏移；游标模式（params.CursorMode）按 (排序值, id) 取 params.After 之后的一页，
+// 并返回下一页游标（没有更多时为空）。total 仅在 params.WantTotal() 时统计
+func (db *DB) List(ledgerID int64, params *models.QueryParams) ([]*models.Record, int64, string, error) {
 	params.Normalize()
-	offset := (params.Page - 1) * params.PageSize
+	where, args, search := recordFilter(ledgerID, params)
 
-	var args []interface{}
-	where := "1=1"
+	// count
+	var total int64
+	if params.WantTotal() {
+		countQuery := "SELECT COUNT(*) FROM records WHERE " + where
+		if err := db.conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	// list：排序字段与方向均来自白名单，不直接拼接用户输入
+	sort, order := params.SortField(), params.SortOrder()
+	sortCol := recordSortColumns[sort]
+	dir, cmp := "DESC", "<"
+	if order == "asc" {
+		dir, cmp = "ASC", ">"
+	}
+	columns, orderBy := recordColumns, ""
+	var selectArgs []interface{}
+	if search.match != "" {
+		columns += `,
+			(SELECT snippet(records_fts, -1, char(1), char(2), '…', 16) FROM records_fts WHERE records_fts MATCH ? AND rowid = records.id) AS search_snippet,
+			(SELECT bm25(records_fts) FROM records_fts WHERE records_fts MATCH ? AND rowid = records.id) AS search_rank`
+		selectArgs = append(selectArgs, search.match, search.match)
+	}
+	if sort == models.SortRelevance {
+		// 相关度越高 bm25 越小；无法计算相关度（只有过短的词）时按日期倒序
+		if search.match != "" {
+			orderBy = "search_rank, "
+		}
+		sortCol, dir = "date", "DESC"
+	}
+	orderBy += sortCol + ` ` + dir + `, id ` + dir
+	limit, offset := params.PageSize, (params.Page-1)*params.PageSize
+	if params.CursorMode {
+		// 多取一条判断是否还有下一页
+		limit, offset = params.PageSize+1, 0
+		if after := params.After; after != nil {
+			var v interface{} = after.V
+			if sort == "amount" {
+				v = after.N
+			}
+			where += " AND (" + sortCol + " " + cmp + " ? OR (" + sortCol + " = ? AND id " + cmp + " ?))"
+			args = append(args, v, v, after.ID)
+		}
+	}
+	query := `SELECT ` + columns + `
+	          FROM records WHERE ` + where + ` ORDER BY ` + orderBy + ` LIMIT ? OFFSET ?`
+	args = append(append(selectArgs, args...), limit, offset)
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer rows.Close()
+
+	var list []*models.Record
+	for rows.Next() {
+		var sc rowScanner = rows
+		var snippet sql.NullString
+		var rank sql.NullFloat64
+		if search.match != "" {
+			sc = extraScanner{rows: rows, extra: []interface{}{&snippet, &rank}}
+		}
+		r, err := scanRecord(sc)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		r.Snippet = highlightSnippet(snippet.String)
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, "", err
+	}
+	next := ""
+	if params.CursorMode && len(list) > params.PageSize {
+		list = list[:params.PageSize]
+		last := list[len(list)-1]
+		cur := models.RecordCursor{Sort: sort, Order: order, ID: last.ID}
+		switch sort {
+		case "date":
+			cur.V = last.Date
+		case "amount":
+			cur.N = int64(last.Amount)
+		case "category":
+			cur.V = last.Category
+		case "created_at":
+			cur.V = last.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		next = models.EncodeCursor(cur)
+	}
+	if err := db.attachTags(list); err != nil {
+		return nil, 0, "", err
+	}
+	if err := db.attachSplits(list); err != nil {
+		return nil, 0, "", err
+	}
+	return list, total, next, nil
+}
+
+// recordFilter 按查询参数生成 records 的筛选条件，同时返回解析后的关键字
+func recordFilter(ledgerID int64, params *models.QueryParams) (string, []interface{}, searchQuery) {
+	args := []interface{}{ledgerID}
+	where := "ledger_id = ? AND deleted_at IS NULL"
 
 	if params.StartDate != "" {
 		where += " AND date >= ?"
@@ -102,80 +505,231 @@ func (db *DB) List(params *models.QueryParams) ([]*models.Record, int64, error)
 		where += " AND date <= ?"
 		args = append(args, params.EndDate)
 	}
+	if params.ExcludeTransfers {
+		where += " AND transfer_id IS NULL"
+	}
+	// 关键字搜索：可索引的词走全文索引并计算相关度与高亮片段，过短的词按 LIKE 匹配
+	var search searchQuery
 	if params.Keyword != "" {
-		where += " AND (description LIKE ? OR category LIKE ?)"
-		kw := "%" + params.Keyword + "%"
-		args = append(args, kw, kw)
+		search = parseSearch(params.Keyword)
+		if search.match != "" {
+			where += " AND id IN (SELECT rowid FROM records_fts WHERE records_fts MATCH ?)"
+			args = append(args, search.match)
+		}
+		for _, t := range search.likes {
+			where += ` AND (description LIKE ? ESCAPE '\' OR category LIKE ? ESCAPE '\' OR payee LIKE ? ESCAPE '\')`
+			kw := "%" + escapeLike(t) + "%"
+			args = append(args, kw, kw, kw)
+		}
 	}
-
-	// count
-	var total int64
-	countQuery := "SELECT COUNT(*) FROM records WHERE " + where
-	if err := db.conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
+	if params.CategoryID > 0 {
+		where += " AND " + categoryCond("SELECT id FROM categories WHERE id = ? OR parent_id = ?")
+		args = append(args, params.CategoryID, params.CategoryID, params.CategoryID, params.CategoryID)
+	}
+	if params.PayeeID > 0 {
+		where += " AND payee_id = ?"
+		args = append(args, params.PayeeID)
+	}
+	if tags := params.TagList(); len(tags) > 0 {
+		cond, condArgs := tagCond("id", ledgerID, tags, params.TagMode == models.TagModeAll)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	switch params.Type {
+	case models.CategoryTypeIncome:
+		where += " AND amount > 0"
+	case models.CategoryTypeExpense:
+		where += " AND amount < 0"
 	}
+	if params.MinAmountValue != nil {
+		where += " AND ABS(amount) >= ?"
+		args = append(args, *params.MinAmountValue)
+	}
+	if params.MaxAmountValue != nil {
+		where += " AND ABS(amount) <= ?"
+		args = append(args, *params.MaxAmountValue)
+	}
+	if len(params.CategoryIDList) > 0 {
+		marks := strings.TrimSuffix(strings.Repeat("?,", len(params.CategoryIDList)), ",")
+		where += " AND " + categoryCond("SELECT id FROM categories WHERE id IN ("+marks+") OR parent_id IN ("+marks+")")
+		for i := 0; i < 4; i++ {
+			for _, id := range params.CategoryIDList {
+				args = append(args, id)
+			}
+		}
+	}
+	for i, cond := range []string{
+		" AND datetime(created_at) >= ?", " AND datetime(created_at) <= ?",
+		" AND datetime(updated_at) >= ?", " AND datetime(updated_at) <= ?",
+	} {
+		if params.TimeRanges[i] != "" {
+			where += cond
+			args = append(args, params.TimeRanges[i])
+		}
+	}
+	return where, args, search
+}
 
-	// list
-	query := `SELECT id, date, amount, category, description, created_at, updated_at 
-	          FROM records WHERE ` + where + ` ORDER BY date DESC, id DESC LIMIT ? OFFSET ?`
-	args = append(args, params.PageSize, offset)
-	rows, err := db.conn.Query(query, args...)
+// categoryCond 记录或其任一拆分行的分类属于子查询 sub 的结果，sub 的参数须提供两遍
+func categoryCond(sub string) string {
+	return "(category_id IN (" + sub + ") OR id IN (SELECT record_id FROM record_splits WHERE category_id IN (" + sub + ")))"
+}
+
+// ListIDs 符合筛选条件的全部记录 ID（不分页），按日期、ID 排序
+func (db *DB) ListIDs(ledgerID int64, params *models.QueryParams) ([]int64, error) {
+	where, args, _ := recordFilter(ledgerID, params)
+	rows, err := db.conn.Query(`SELECT id FROM records WHERE `+where+` ORDER BY date, id`, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
-
-	var list []*models.Record
+	var ids []int64
 	for rows.Next() {
-		var r models.Record
-		if err := rows.Scan(&r.ID, &r.Date, &r.Amount, &r.Category, &r.Description, &r.CreatedAt, &r.UpdatedAt); err != nil {
-			return nil, 0, err
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
-		list = append(list, &r)
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Update 更新记录并写入修订，返回本次修订（没有实际变化时为 nil）。
+// version 非 0 时须与记录当前版本一致，否则返回 ErrVersionConflict
+func (db *DB) Update(ledgerID, id, userID, version int64, req *models.UpdateRecordRequest) (*models.RecordRevision, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rev, err := updateRecord(tx, ledgerID, id, version, req, revisionMeta{UserID: userID, Action: models.RevisionUpdate})
+	if err != nil {
+		return nil, err
 	}
-	return list, total, nil
+	return rev, tx.Commit()
 }
 
-func (db *DB) Update(id int64, req *models.UpdateRecordRequest) error {
-	cur, err := db.GetByID(id)
+func updateRecord(q querier, ledgerID, id, version int64, req *models.UpdateRecordRequest, meta revisionMeta) (*models.RecordRevision, error) {
+	return trackRevision(q, ledgerID, id, meta, func() error {
+		return applyUpdate(q, ledgerID, id, version, req)
+	})
+}
+
+func applyUpdate(q querier, ledgerID, id, version int64, req *models.UpdateRecordRequest) error {
+	cur, err := getRecord(q, ledgerID, id)
 	if err != nil || cur == nil {
 		return sql.ErrNoRows
 	}
-	date, amount, category, desc := cur.Date, cur.Amount, cur.Category, cur.Description
+	if version != 0 && version != cur.Version {
+		return ErrVersionConflict
+	}
+	if cur.TransferID != nil {
+		return ErrTransferLeg
+	}
+	date, amount, currency, categoryID, category, desc, payee, payeeID, accountID := cur.Date, cur.Amount, cur.Currency, cur.CategoryID, cur.Category, cur.Description, cur.Payee, cur.PayeeID, cur.AccountID
 	if req.Date != nil {
 		date = *req.Date
 	}
 	if req.Amount != nil {
 		amount = *req.Amount
 	}
+	if req.Currency != nil {
+		currency = *req.Currency
+	}
+	if req.CategoryID != nil {
+		categoryID = req.CategoryID
+	}
 	if req.Category != nil {
 		category = *req.Category
 	}
 	if req.Description != nil {
 		desc = *req.Description
 	}
-	res, err := db.conn.Exec(
-		`UPDATE records SET date=?, amount=?, category=?, description=?, updated_at=CURRENT_TIMESTAMP WHERE id=?`,
-		date, amount, category, desc, id,
+	if req.Payee != nil || req.PayeeID != nil {
+		name := ""
+		if req.Payee != nil {
+			name = *req.Payee
+		}
+		if payeeID, payee, err = linkPayee(q, ledgerID, req.PayeeID, name); err != nil {
+			return err
+		}
+	}
+	if req.AccountID != nil {
+		accountID = req.AccountID
+	}
+	if req.Splits != nil && len(*req.Splits) > 0 {
+		// 拆分记录的分类由各行给出
+		categoryID, category = nil, ""
+	}
+	res, err := q.Exec(
+		`UPDATE records SET date=?, amount=?, currency=?, category_id=?, category=?, description=?, payee=?, payee_id=?, account_id=?, version=version+1, updated_at=CURRENT_TIMESTAMP
+		 WHERE id=? AND ledger_id=? AND version=? AND deleted_at IS NULL`,
+		date, amount, currency, nullID(categoryID), category, desc, payee, nullID(payeeID), nullID(accountID), id, ledgerID, cur.Version,
 	)
 	if err != nil {
 		return err
 	}
 	n, _ := res.RowsAffected()
 	if n == 0 {
-		return sql.ErrNoRows
+		return ErrVersionConflict
 	}
-	return nil
+	if req.Tags != nil {
+		if err := setRecordTags(q, ledgerID, id, *req.Tags); err != nil {
+			return err
+		}
+	}
+	if req.Splits != nil {
+		if err := setRecordSplits(q, ledgerID, id, *req.Splits); err != nil {
+			return err
+		}
+	}
+	return checkSplits(q, id)
 }
 
-func (db *DB) Delete(id int64) error {
-	res, err := db.conn.Exec("DELETE FROM records WHERE id=?", id)
+// Delete 将记录移入回收站；若为转账分录则在同一事务中将整笔转账移入回收站。version 含义同 Update
+func (db *DB) Delete(ledgerID, id, userID, version int64) error {
+	tx, err := db.conn.Begin()
 	if err != nil {
 		return err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return sql.ErrNoRows
+	defer tx.Rollback()
+	if err := deleteRecord(tx, ledgerID, id, userID, version); err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit()
+}
+
+// deleteRecord 软删除记录并写入 delete 修订，标签关联保留以便恢复；转账分录连同整笔转账删除
+func deleteRecord(q querier, ledgerID, id, userID, version int64) error {
+	var transferID sql.NullInt64
+	var cur int64
+	err := q.QueryRow(`SELECT transfer_id, version FROM records WHERE id=? AND ledger_id=? AND deleted_at IS NULL`, id, ledgerID).Scan(&transferID, &cur)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != cur {
+		return ErrVersionConflict
+	}
+	if transferID.Valid {
+		// 分录版本已在上面校验
+		return deleteTransfer(q, ledgerID, transferID.Int64, userID, 0)
+	}
+	_, err = trackRevision(q, ledgerID, id, revisionMeta{UserID: userID, Action: models.RevisionDelete}, func() error {
+		_, err := q.Exec(`UPDATE records SET deleted_at=?, version=version+1 WHERE id=? AND ledger_id=?`, deletedNow(), id, ledgerID)
+		return err
+	})
+	return err
+}
+
+// ErrVersionConflict 记录已被修改，客户端持有的版本已过期
+var ErrVersionConflict = errors.New("记录已被修改，请刷新后重试")
+
+// deletedNow 软删除时间（UTC），同一次删除的多行使用同一时间，保证回收站按时间清理时一起清除
+func deletedNow() string {
+	return dbTime(time.Now())
+}
+
+// dbTime 与 SQLite CURRENT_TIMESTAMP 格式一致的 UTC 时间文本，可直接按字符串比较
+func dbTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
 }
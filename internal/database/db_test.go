@@ -0,0 +1,17 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB 在临时目录中创建空数据库
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+func (db *DB) migrateIdempotency() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			headers TEXT,
+			body BLOB,
+			expires_at TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, key)
+		);
+		CREATE INDEX IF NOT EXISTS idx_idempotency_expires ON idempotency_keys(expires_at);
+	`)
+	return err
+}
+
+// ReserveIdempotencyKey 为用户占用幂等键（状态为处理中），保留到 expiresAt。
+// 键已存在且未过期时不做修改，返回已有记录；占用成功时返回 nil
+func (db *DB) ReserveIdempotencyKey(userID int64, key, requestHash string, expiresAt time.Time) (*models.IdempotencyRecord, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND key = ? AND expires_at <= ?`, userID, key, dbTime(time.Now())); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		userID, key, requestHash, dbTime(expiresAt))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil, tx.Commit()
+	}
+	rec := &models.IdempotencyRecord{UserID: userID, Key: key}
+	var headers sql.NullString
+	var expires string
+	if err := tx.QueryRow(`SELECT request_hash, status, headers, body, expires_at FROM idempotency_keys WHERE user_id = ? AND key = ?`, userID, key).
+		Scan(&rec.RequestHash, &rec.Status, &headers, &rec.Body, &expires); err != nil {
+		return nil, err
+	}
+	if headers.Valid {
+		_ = json.Unmarshal([]byte(headers.String), &rec.Headers)
+	}
+	rec.ExpiresAt, _ = time.Parse("2006-01-02 15:04:05", expires)
+	return rec, tx.Commit()
+}
+
+// CompleteIdempotencyKey 保存幂等键对应请求的响应，之后的重复请求直接重放
+func (db *DB) CompleteIdempotencyKey(userID int64, key string, status int, headers map[string]string, body []byte) error {
+	data, _ := json.Marshal(headers)
+	_, err := db.conn.Exec(`UPDATE idempotency_keys SET status = ?, headers = ?, body = ? WHERE user_id = ? AND key = ?`,
+		status, string(data), body, userID, key)
+	return err
+}
+
+// ReleaseIdempotencyKey 释放幂等键（如请求处理失败），允许客户端以同一键重试
+func (db *DB) ReleaseIdempotencyKey(userID int64, key string) error {
+	_, err := db.conn.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND key = ?`, userID, key)
+	return err
+}
+
+// PurgeIdempotencyKeys 删除在 now 之前过期的幂等键，返回删除数
+func (db *DB) PurgeIdempotencyKeys(now time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= ?`, dbTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
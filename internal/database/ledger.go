@@ -0,0 +1,286 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+)
+
+// ErrLastOwner 账本至少保留一名所有者
+var ErrLastOwner = errors.New("账本至少需要保留一名所有者")
+
+const defaultLedgerName = "默认账本"
+
+func (db *DB) migrateLedgers() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS ledgers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS ledger_members (
+			ledger_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (ledger_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_ledger_members_user ON ledger_members(user_id);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN ledger_id INTEGER`)
+	_, _ = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_ledger ON records(ledger_id, date)`)
+	return nil
+}
+
+// backfillRecordLedgers 将未归入账本的记录放入其所属用户的默认账本
+func (db *DB) backfillRecordLedgers() error {
+	rows, err := db.conn.Query(`SELECT DISTINCT user_id FROM records WHERE ledger_id IS NULL AND user_id IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var uid int64
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, uid)
+	}
+	rows.Close()
+	for _, uid := range userIDs {
+		ledgerID, err := db.DefaultLedgerID(uid)
+		if err != nil {
+			return err
+		}
+		if _, err := db.conn.Exec(`UPDATE records SET ledger_id = ? WHERE ledger_id IS NULL AND user_id = ?`, ledgerID, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultLedgerID 返回用户拥有的第一个账本，没有则自动创建
+func (db *DB) DefaultLedgerID(userID int64) (int64, error) {
+	var id int64
+	err := db.conn.QueryRow(
+		`SELECT ledger_id FROM ledger_members WHERE user_id = ? AND role = ? ORDER BY ledger_id LIMIT 1`,
+		userID, models.LedgerRoleOwner,
+	).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	l := &models.Ledger{Name: defaultLedgerName}
+	if err := db.CreateLedger(l, userID); err != nil {
+		return 0, err
+	}
+	return l.ID, nil
+}
+
+// LedgerRole 返回用户在账本中的角色，非成员返回空字符串
+func (db *DB) LedgerRole(ledgerID, userID int64) (string, error) {
+	var role string
+	err := db.conn.QueryRow(
+		`SELECT role FROM ledger_members WHERE ledger_id = ? AND user_id = ?`, ledgerID, userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// CreateLedger 创建账本，创建者成为所有者
+func (db *DB) CreateLedger(l *models.Ledger, ownerID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO ledgers (name, created_by) VALUES (?, ?)`, l.Name, ownerID)
+	if err != nil {
+		return err
+	}
+	id, _ := res.LastInsertId()
+	if _, err := tx.Exec(
+		`INSERT INTO ledger_members (ledger_id, user_id, role) VALUES (?, ?, ?)`, id, ownerID, models.LedgerRoleOwner,
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	l.ID = id
+	l.CreatedBy = ownerID
+	l.Role = models.LedgerRoleOwner
+	return nil
+}
+
+// ListLedgers 用户参与的账本
+func (db *DB) ListLedgers(userID int64) ([]*models.Ledger, error) {
+	rows, err := db.conn.Query(`
+		SELECT l.id, l.name, l.created_by, m.role, l.created_at
+		FROM ledgers l JOIN ledger_members m ON m.ledger_id = l.id
+		WHERE m.user_id = ? ORDER BY l.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Ledger
+	for rows.Next() {
+		var l models.Ledger
+		if err := rows.Scan(&l.ID, &l.Name, &l.CreatedBy, &l.Role, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &l)
+	}
+	return list, nil
+}
+
+// ListLedgerMembers 账本成员列表
+func (db *DB) ListLedgerMembers(ledgerID int64) ([]*models.LedgerMember, error) {
+	rows, err := db.conn.Query(`
+		SELECT m.ledger_id, m.user_id, u.username, m.role, m.created_at
+		FROM ledger_members m JOIN users u ON u.id = m.user_id
+		WHERE m.ledger_id = ? ORDER BY m.created_at, m.user_id
+	`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.LedgerMember
+	for rows.Next() {
+		var m models.LedgerMember
+		if err := rows.Scan(&m.LedgerID, &m.UserID, &m.Username, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &m)
+	}
+	return list, nil
+}
+
+// AddLedgerMember 添加账本成员并返回新成员，已是成员时返回唯一约束错误
+func (db *DB) AddLedgerMember(ledgerID, userID int64, role string) (*models.LedgerMember, error) {
+	_, err := db.conn.Exec(
+		`INSERT INTO ledger_members (ledger_id, user_id, role) VALUES (?, ?, ?)`, ledgerID, userID, role,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var m models.LedgerMember
+	err = db.conn.QueryRow(`
+		SELECT m.ledger_id, m.user_id, u.username, m.role, m.created_at
+		FROM ledger_members m JOIN users u ON u.id = m.user_id
+		WHERE m.ledger_id = ? AND m.user_id = ?
+	`, ledgerID, userID).Scan(&m.LedgerID, &m.UserID, &m.Username, &m.Role, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// UpdateLedgerMemberRole 修改成员角色，不允许移除最后一名所有者
+func (db *DB) UpdateLedgerMemberRole(ledgerID, userID int64, role string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if role != models.LedgerRoleOwner {
+		if err := checkNotLastOwner(tx, ledgerID, userID); err != nil {
+			return err
+		}
+	}
+	res, err := tx.Exec(`UPDATE ledger_members SET role = ? WHERE ledger_id = ? AND user_id = ?`, role, ledgerID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// RemoveLedgerMember 移除账本成员，不允许移除最后一名所有者
+func (db *DB) RemoveLedgerMember(ledgerID, userID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := checkNotLastOwner(tx, ledgerID, userID); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM ledger_members WHERE ledger_id = ? AND user_id = ?`, ledgerID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func checkNotLastOwner(tx *sql.Tx, ledgerID, userID int64) error {
+	var owners int
+	var isOwner bool
+	err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(MAX(user_id = ?), 0) FROM ledger_members WHERE ledger_id = ? AND role = ?
+	`, userID, ledgerID, models.LedgerRoleOwner).Scan(&owners, &isOwner)
+	if err != nil {
+		return err
+	}
+	if isOwner && owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// soleMemberLedgers 仅有 userID 一名成员的账本
+func soleMemberLedgers(tx *sql.Tx, userID int64) ([]int64, error) {
+	rows, err := tx.Query(`
+		SELECT m.ledger_id FROM ledger_members m
+		WHERE m.user_id = ?
+		  AND NOT EXISTS (SELECT 1 FROM ledger_members o WHERE o.ledger_id = m.ledger_id AND o.user_id <> m.user_id)
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// purgeLedger 物理删除账本及其中的全部数据（含回收站），返回须从存储后端删除的附件 key
+func purgeLedger(tx *sql.Tx, ledgerID int64) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM transfers WHERE ledger_id = ?`, ledgerID); err != nil {
+		return nil, err
+	}
+	keys, err := purgeRecords(tx, `ledger_id = ?`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range []string{"attachments", "record_revisions", "recurring_rules", "budgets", "payee_aliases", "payees", "tags", "categories", "accounts", "ledger_members"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE ledger_id = ?`, ledgerID); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`DELETE FROM ledgers WHERE id = ?`, ledgerID)
+	return keys, err
+}
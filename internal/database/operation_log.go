@@ -1,22 +1,56 @@
 package database
 
 import (
+	"account-service/internal/models"
 	"fmt"
 )
 
 // 操作类型常量
 const (
-	OpLogin        = "login"
-	OpLogout       = "logout"
-	OpCreateRecord = "create_record"
-	OpUpdateRecord = "update_record"
-	OpDeleteRecord = "delete_record"
-	OpAddUser      = "add_user"
-	OpUpdateUser   = "update_user"
-	OpDeleteUser   = "delete_user"
-	OpChangePwd    = "change_password"
-	OpTOTPEnable   = "totp_enable"
-	OpTOTPDisable  = "totp_disable"
+	OpLogin            = "login"
+	OpLogout           = "logout"
+	OpCreateRecord     = "create_record"
+	OpUpdateRecord     = "update_record"
+	OpDeleteRecord     = "delete_record"
+	OpAddUser          = "add_user"
+	OpUpdateUser       = "update_user"
+	OpDeleteUser       = "delete_user"
+	OpChangePwd        = "change_password"
+	OpTOTPEnable       = "totp_enable"
+	OpTOTPDisable      = "totp_disable"
+	OpCreateLedger     = "create_ledger"
+	OpInviteMember     = "invite_member"
+	OpUpdateMember     = "update_member"
+	OpRemoveMember     = "remove_member"
+	OpCreateAccount    = "create_account"
+	OpUpdateAccount    = "update_account"
+	OpDeleteAccount    = "delete_account"
+	OpCreateTransfer   = "create_transfer"
+	OpUpdateTransfer   = "update_transfer"
+	OpDeleteTransfer   = "delete_transfer"
+	OpUpdateSettings   = "update_settings"
+	OpSaveRates        = "save_rates"
+	OpDeleteRate       = "delete_rate"
+	OpCreateCategory   = "create_category"
+	OpUpdateCategory   = "update_category"
+	OpDeleteCategory   = "delete_category"
+	OpDeleteTag        = "delete_tag"
+	OpCreateBudget     = "create_budget"
+	OpUpdateBudget     = "update_budget"
+	OpDeleteBudget     = "delete_budget"
+	OpCreateRecurring  = "create_recurring"
+	OpUpdateRecurring  = "update_recurring"
+	OpDeleteRecurring  = "delete_recurring"
+	OpRecurringRecord  = "recurring_record"
+	OpRestoreRecord    = "restore_record"
+	OpPurgeRecord      = "purge_record"
+	OpEmptyTrash       = "empty_trash"
+	OpRevertRecord     = "revert_record"
+	OpUploadAttachment = "upload_attachment"
+	OpDeleteAttachment = "delete_attachment"
+	OpCreatePayee      = "create_payee"
+	OpUpdatePayee      = "update_payee"
+	OpDeletePayee      = "delete_payee"
 )
 
 func (db *DB) migrateOperationLogs() error {
@@ -61,37 +95,59 @@ type OperationLog struct {
 	CreatedAt  string `json:"created_at"`
 }
 
-func (db *DB) ListOperationLogs(page, pageSize int, userID *int64, action string) ([]*OperationLog, int64, error) {
-	if page <= 0 {
-		page = 1
+// OperationLogQuery 操作日志查询条件。CursorMode 为 true 时按 id 游标分页，只取 id 小于 BeforeID（为 0 时不限）的日志
+type OperationLogQuery struct {
+	Page       int
+	PageSize   int
+	UserID     *int64
+	Action     string
+	CursorMode bool
+	BeforeID   int64
+	WithTotal  bool
+}
+
+// ListOperationLogs 按 id 倒序列出操作日志；游标模式下返回下一页游标（没有更多时为空），total 仅在 WithTotal 时统计
+func (db *DB) ListOperationLogs(q *OperationLogQuery) ([]*OperationLog, int64, string, error) {
+	if q.Page <= 0 {
+		q.Page = 1
 	}
-	if pageSize <= 0 || pageSize > 100 {
-		pageSize = 20
+	if q.PageSize <= 0 || q.PageSize > 100 {
+		q.PageSize = 20
 	}
-	offset := (page - 1) * pageSize
 
 	where := "1=1"
 	var args []interface{}
-	if userID != nil {
+	if q.UserID != nil {
 		where += " AND user_id = ?"
-		args = append(args, *userID)
+		args = append(args, *q.UserID)
 	}
-	if action != "" {
+	if q.Action != "" {
 		where += " AND action = ?"
-		args = append(args, action)
+		args = append(args, q.Action)
 	}
 
 	var total int64
-	if err := db.conn.QueryRow("SELECT COUNT(*) FROM operation_logs WHERE "+where, args...).Scan(&total); err != nil {
-		return nil, 0, err
+	if q.WithTotal {
+		if err := db.conn.QueryRow("SELECT COUNT(*) FROM operation_logs WHERE "+where, args...).Scan(&total); err != nil {
+			return nil, 0, "", err
+		}
 	}
 
+	limit, offset := q.PageSize, (q.Page-1)*q.PageSize
+	if q.CursorMode {
+		// 多取一条判断是否还有下一页
+		limit, offset = q.PageSize+1, 0
+		if q.BeforeID > 0 {
+			where += " AND id < ?"
+			args = append(args, q.BeforeID)
+		}
+	}
 	query := `SELECT id, user_id, username, action, COALESCE(target_type,''), COALESCE(target_id,''), COALESCE(detail,''), COALESCE(ip,''), COALESCE(user_agent,''), created_at 
 	          FROM operation_logs WHERE ` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
-	args = append(args, pageSize, offset)
+	args = append(args, limit, offset)
 	rows, err := db.conn.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 	defer rows.Close()
 
@@ -100,10 +156,15 @@ func (db *DB) ListOperationLogs(page, pageSize int, userID *int64, action string
 		var l OperationLog
 		var createdAt interface{}
 		if err := rows.Scan(&l.ID, &l.UserID, &l.Username, &l.Action, &l.TargetType, &l.TargetID, &l.Detail, &l.IP, &l.UserAgent, &createdAt); err != nil {
-			return nil, 0, err
+			return nil, 0, "", err
 		}
 		l.CreatedAt = fmt.Sprint(createdAt)
 		list = append(list, &l)
 	}
-	return list, total, nil
+	next := ""
+	if q.CursorMode && len(list) > q.PageSize {
+		list = list[:q.PageSize]
+		next = models.EncodeCursor(models.LogCursor{ID: list[len(list)-1].ID})
+	}
+	return list, total, next, nil
 }
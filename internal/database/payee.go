@@ -0,0 +1,310 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrPayeeNameTaken 名称或别名已被账本内其他交易对方使用
+var ErrPayeeNameTaken = errors.New("名称或别名已被其他交易对方使用")
+
+// migratePayees 交易对方及其别名，名称与别名在账本内不区分大小写唯一
+func (db *DB) migratePayees() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS payees (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_payees_name ON payees(ledger_id, name COLLATE NOCASE);
+		CREATE TABLE IF NOT EXISTS payee_aliases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			payee_id INTEGER NOT NULL,
+			ledger_id INTEGER NOT NULL,
+			alias TEXT NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_payee_aliases_alias ON payee_aliases(ledger_id, alias COLLATE NOCASE);
+		CREATE INDEX IF NOT EXISTS idx_payee_aliases_payee ON payee_aliases(payee_id);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN payee_id INTEGER`)
+	_, err = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_payee_id ON records(payee_id)`)
+	return err
+}
+
+// backfillPayees 按旧记录中去除首尾空白后的交易对方名称建立交易对方并关联
+func (db *DB) backfillPayees() error {
+	const pending = `payee_id IS NULL AND ledger_id IS NOT NULL AND TRIM(payee) <> ''`
+	if _, err := db.conn.Exec(`
+		INSERT INTO payees (ledger_id, name)
+		SELECT ledger_id, TRIM(payee) FROM records WHERE ` + pending + `
+		GROUP BY ledger_id, TRIM(payee) COLLATE NOCASE
+		ON CONFLICT DO NOTHING
+	`); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`
+		UPDATE records SET payee_id = (
+			SELECT p.id FROM payees p WHERE p.ledger_id = records.ledger_id AND p.name = TRIM(records.payee) COLLATE NOCASE
+		)
+		WHERE ` + pending)
+	return err
+}
+
+// payeeStatsColumns 交易对方的列及使用统计，需 LEFT JOIN records r（不含回收站）后按 p.id 分组
+const payeeStatsColumns = `p.id, p.ledger_id, p.name, COUNT(r.id), COALESCE(MAX(r.date), ''), p.created_at, p.updated_at`
+
+// payeeScore 自动补全的排序分：每笔记录计 1 分并按日期距今衰减（30 天减半），兼顾使用频率与最近使用
+const payeeScore = `COALESCE(SUM(1.0 / (1 + MAX(julianday('now') - julianday(r.date), 0) / 30.0)), 0)`
+
+func scanPayee(s rowScanner) (*models.Payee, error) {
+	var p models.Payee
+	if err := s.Scan(&p.ID, &p.LedgerID, &p.Name, &p.UseCount, &p.LastUsed, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.Aliases = []string{}
+	return &p, nil
+}
+
+// ListPayees 账本内的交易对方，按使用频率与最近使用排序。prefix 非空时按名称或别名前缀匹配（不区分大小写）
+func (db *DB) ListPayees(ledgerID int64, prefix string, limit int) ([]*models.Payee, error) {
+	where := `p.ledger_id = ?`
+	args := []interface{}{ledgerID}
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		like := escapeLike(prefix) + "%"
+		where += ` AND (p.name LIKE ? ESCAPE '\' OR p.id IN (SELECT payee_id FROM payee_aliases WHERE ledger_id = ? AND alias LIKE ? ESCAPE '\'))`
+		args = append(args, like, ledgerID, like)
+	}
+	rows, err := db.conn.Query(`SELECT `+payeeStatsColumns+` FROM payees p
+		LEFT JOIN records r ON r.payee_id = p.id AND r.deleted_at IS NULL
+		WHERE `+where+`
+		GROUP BY p.id ORDER BY `+payeeScore+` DESC, MAX(r.date) DESC, p.name LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*models.Payee{}
+	for rows.Next() {
+		p, err := scanPayee(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, db.attachAliases(list)
+}
+
+// GetPayee 获取账本内的交易对方（含别名与使用统计），不存在时返回 nil
+func (db *DB) GetPayee(ledgerID, id int64) (*models.Payee, error) {
+	p, err := scanPayee(db.conn.QueryRow(`SELECT `+payeeStatsColumns+` FROM payees p
+		LEFT JOIN records r ON r.payee_id = p.id AND r.deleted_at IS NULL
+		WHERE p.id = ? AND p.ledger_id = ? GROUP BY p.id`, id, ledgerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, db.attachAliases([]*models.Payee{p})
+}
+
+func (db *DB) attachAliases(list []*models.Payee) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.Payee, len(list))
+	args := make([]interface{}, len(list))
+	for i, p := range list {
+		byID[p.ID] = p
+		args[i] = p.ID
+	}
+	rows, err := db.conn.Query(`SELECT payee_id, alias FROM payee_aliases WHERE payee_id IN (`+placeholders(len(args))+`) ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var alias string
+		if err := rows.Scan(&id, &alias); err != nil {
+			return err
+		}
+		byID[id].Aliases = append(byID[id].Aliases, alias)
+	}
+	return rows.Err()
+}
+
+// findPayee 按名称或别名（不区分大小写）查找交易对方，返回其 ID 与名称，不存在时 ID 为 0
+func findPayee(q querier, ledgerID int64, name string) (int64, string, error) {
+	var id int64
+	var canonical string
+	err := q.QueryRow(`SELECT id, name FROM payees WHERE ledger_id = ? AND name = ? COLLATE NOCASE
+		UNION ALL
+		SELECT p.id, p.name FROM payee_aliases a JOIN payees p ON p.id = a.payee_id WHERE a.ledger_id = ? AND a.alias = ? COLLATE NOCASE
+		LIMIT 1`, ledgerID, name, ledgerID, name).Scan(&id, &canonical)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return id, canonical, err
+}
+
+// linkPayee 确定记录的交易对方：给出 PayeeID 时取其名称；否则按名称或别名匹配，不存在时自动创建；名称为空表示不关联
+func linkPayee(q querier, ledgerID int64, payeeID *int64, name string) (*int64, string, error) {
+	if payeeID != nil && *payeeID != 0 {
+		var canonical string
+		err := q.QueryRow(`SELECT name FROM payees WHERE id = ? AND ledger_id = ?`, *payeeID, ledgerID).Scan(&canonical)
+		return payeeID, canonical, err
+	}
+	name = strings.TrimSpace(name)
+	if payeeID != nil || name == "" {
+		return nil, "", nil
+	}
+	id, canonical, err := findPayee(q, ledgerID, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if id == 0 {
+		res, err := q.Exec(`INSERT INTO payees (ledger_id, name) VALUES (?, ?)`, ledgerID, name)
+		if err != nil {
+			return nil, "", err
+		}
+		id, _ = res.LastInsertId()
+		canonical = name
+	}
+	return &id, canonical, nil
+}
+
+// payeeNameTaken 名称或别名是否已被 exceptID 以外的交易对方使用
+func payeeNameTaken(q querier, ledgerID, exceptID int64, names []string) (bool, error) {
+	for _, name := range names {
+		id, _, err := findPayee(q, ledgerID, name)
+		if err != nil {
+			return false, err
+		}
+		if id != 0 && id != exceptID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func setPayeeAliases(q querier, ledgerID, payeeID int64, aliases []string) error {
+	if _, err := q.Exec(`DELETE FROM payee_aliases WHERE payee_id = ?`, payeeID); err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		if _, err := q.Exec(`INSERT INTO payee_aliases (payee_id, ledger_id, alias) VALUES (?, ?, ?)`, payeeID, ledgerID, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePayee 创建交易对方及其别名，名称或别名已被使用时返回 ErrPayeeNameTaken
+func (db *DB) CreatePayee(p *models.Payee) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	taken, err := payeeNameTaken(tx, p.LedgerID, 0, append([]string{p.Name}, p.Aliases...))
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPayeeNameTaken
+	}
+	res, err := tx.Exec(`INSERT INTO payees (ledger_id, name) VALUES (?, ?)`, p.LedgerID, p.Name)
+	if err != nil {
+		return err
+	}
+	p.ID, _ = res.LastInsertId()
+	if err := setPayeeAliases(tx, p.LedgerID, p.ID, p.Aliases); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePayee 修改名称与别名，改名同步到已关联的记录
+func (db *DB) UpdatePayee(ledgerID int64, p *models.Payee) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	taken, err := payeeNameTaken(tx, ledgerID, p.ID, append([]string{p.Name}, p.Aliases...))
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPayeeNameTaken
+	}
+	res, err := tx.Exec(`UPDATE payees SET name=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND ledger_id=?`, p.Name, p.ID, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := setPayeeAliases(tx, ledgerID, p.ID, p.Aliases); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE records SET payee=?, version=version+1 WHERE payee_id=? AND payee<>?`, p.Name, p.ID, p.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePayee 删除交易对方及其别名，已关联的记录解除关联并保留名称
+func (db *DB) DeletePayee(ledgerID, id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM payees WHERE id=? AND ledger_id=?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM payee_aliases WHERE payee_id=?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE records SET payee_id=NULL, version=version+1 WHERE payee_id=?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// payeeBreakdown 按交易对方分项，按合计绝对值降序
+func (db *DB) payeeBreakdown(cte string, args []interface{}) ([]*models.PayeeItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT p.id, p.name, `+sumColumns+`, COALESCE(SUM(s.base_amount), 0)
+		FROM stats s JOIN payees p ON p.id = s.payee_id
+		GROUP BY p.id ORDER BY ABS(SUM(s.base_amount)) DESC, p.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.PayeeItem
+	for rows.Next() {
+		var item models.PayeeItem
+		if err := rows.Scan(&item.PayeeID, &item.Payee, &item.Income, &item.Expense, &item.Count, &item.Total); err != nil {
+			return nil, err
+		}
+		list = append(list, &item)
+	}
+	return list, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"fmt"
+	"time"
+)
+
+// periodStart t 所在分项的起始日
+func periodStart(groupBy string, cal models.Calendar, t time.Time) time.Time {
+	switch groupBy {
+	case models.GroupByWeek:
+		return cal.WeekStartOf(t)
+	case models.GroupByMonth:
+		return cal.MonthStart(t)
+	case models.GroupByQuarter:
+		return cal.QuarterStart(t)
+	case models.GroupByYear:
+		return cal.YearStart(t)
+	}
+	return t
+}
+
+// periodLabel 分项标记：日 2024-02-06，ISO 周 2024-W06，其余周为起始日期，月 2024-02，季度 2024-Q1，年 2024；
+// 月以起始日所在月份命名，季度与年按财年命名
+func periodLabel(groupBy string, cal models.Calendar, start time.Time) string {
+	switch groupBy {
+	case models.GroupByWeek:
+		if cal.WeekStart == models.WeekStartISO || cal.WeekStart == "" {
+			y, w := start.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", y, w)
+		}
+	case models.GroupByMonth:
+		return start.Format("2006-01")
+	case models.GroupByQuarter:
+		y := cal.YearStart(start)
+		months := (start.Year()-y.Year())*12 + int(start.Month()) - int(y.Month())
+		return fmt.Sprintf("%04d-Q%d", y.Year(), months/3+1)
+	case models.GroupByYear:
+		return fmt.Sprintf("%04d", cal.FiscalYear(start))
+	}
+	return start.Format("2006-01-02")
+}
+
+// periodLastDay 分项的最后一天
+func periodLastDay(groupBy string, start time.Time) time.Time {
+	switch groupBy {
+	case models.GroupByWeek:
+		return start.AddDate(0, 0, 6)
+	case models.GroupByMonth:
+		return start.AddDate(0, 1, -1)
+	case models.GroupByQuarter:
+		return start.AddDate(0, 3, -1)
+	case models.GroupByYear:
+		return start.AddDate(1, 0, -1)
+	}
+	return start
+}
+
+// periodBreakdown 按 groupBy 分项：先按日统计，再按 cal 归入所在分项（一条记录只有一个日期，记录数可直接累加）。
+// 分项的起止日期截取到查询范围 [startDate, endDate] 内；没有记录的分项不返回
+func (db *DB) periodBreakdown(cte string, args []interface{}, groupBy string, cal models.Calendar, startDate, endDate string) ([]*models.BreakdownItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT date, `+sumColumns+`
+		FROM stats s
+		GROUP BY date ORDER BY date
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.BreakdownItem
+	var cur *models.BreakdownItem
+	for rows.Next() {
+		var date string
+		var day models.BreakdownItem
+		if err := rows.Scan(&date, &day.Income, &day.Expense, &day.Count); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, err
+		}
+		start := periodStart(groupBy, cal, t)
+		label := periodLabel(groupBy, cal, start)
+		if cur == nil || cur.Period != label {
+			cur = &models.BreakdownItem{
+				Period:    label,
+				StartDate: maxDate(start.Format("2006-01-02"), startDate),
+				EndDate:   minDate(periodLastDay(groupBy, start).Format("2006-01-02"), endDate),
+			}
+			list = append(list, cur)
+		}
+		cur.Income += day.Income
+		cur.Expense += day.Expense
+		cur.Count += day.Count
+		cur.Balance = cur.Income - cur.Expense
+	}
+	return list, rows.Err()
+}
+
+func maxDate(a, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDate(a, b string) string {
+	if a < b {
+		return a
+	}
+	return b
+}
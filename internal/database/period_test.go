@@ -0,0 +1,51 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPeriodLabel(t *testing.T) {
+	fiscal := models.Calendar{YearStartMonth: 4, MonthStartDay: 15}
+	tests := []struct {
+		groupBy string
+		cal     models.Calendar
+		in      string // 所在日期，先经 periodStart 取分项起始日
+		start   string
+		label   string
+		last    string
+	}{
+		{models.GroupByDay, models.Calendar{}, "2024-02-06", "2024-02-06", "2024-02-06", "2024-02-06"},
+		// ISO 周跨年：2024-12-30 属于 2025 年第 1 周
+		{models.GroupByWeek, models.Calendar{}, "2025-01-01", "2024-12-30", "2025-W01", "2025-01-05"},
+		{models.GroupByWeek, models.Calendar{WeekStart: models.WeekStartISO}, "2021-01-03", "2020-12-28", "2020-W53", "2021-01-03"},
+		{models.GroupByWeek, models.Calendar{WeekStart: models.WeekStartSunday}, "2024-01-06", "2023-12-31", "2023-12-31", "2024-01-06"},
+		{models.GroupByWeek, models.Calendar{WeekStart: models.WeekStartSaturday}, "2024-01-06", "2024-01-06", "2024-01-06", "2024-01-12"},
+		{models.GroupByMonth, models.Calendar{}, "2024-02-29", "2024-02-01", "2024-02", "2024-02-29"},
+		{models.GroupByMonth, models.Calendar{MonthStartDay: 28}, "2024-03-01", "2024-02-28", "2024-02", "2024-03-27"},
+		{models.GroupByMonth, models.Calendar{MonthStartDay: 31}, "2024-04-30", "2024-04-01", "2024-04", "2024-04-30"},
+		{models.GroupByMonth, fiscal, "2024-01-10", "2023-12-15", "2023-12", "2024-01-14"},
+		{models.GroupByQuarter, models.Calendar{}, "2024-05-20", "2024-04-01", "2024-Q2", "2024-06-30"},
+		{models.GroupByQuarter, models.Calendar{YearStartMonth: 7}, "2024-06-30", "2024-04-01", "2023-Q4", "2024-06-30"},
+		{models.GroupByQuarter, models.Calendar{YearStartMonth: 7}, "2024-07-01", "2024-07-01", "2024-Q1", "2024-09-30"},
+		{models.GroupByQuarter, fiscal, "2024-04-14", "2024-01-15", "2023-Q4", "2024-04-14"},
+		{models.GroupByYear, models.Calendar{}, "2024-12-31", "2024-01-01", "2024", "2024-12-31"},
+		{models.GroupByYear, fiscal, "2024-04-14", "2023-04-15", "2023", "2024-04-14"},
+		{models.GroupByYear, fiscal, "2024-04-15", "2024-04-15", "2024", "2025-04-14"},
+	}
+	for _, tt := range tests {
+		in, _ := time.Parse("2006-01-02", tt.in)
+		start := periodStart(tt.groupBy, tt.cal, in)
+		if got := start.Format("2006-01-02"); got != tt.start {
+			t.Errorf("periodStart(%s, %+v, %s) = %s, want %s", tt.groupBy, tt.cal, tt.in, got, tt.start)
+			continue
+		}
+		if got := periodLabel(tt.groupBy, tt.cal, start); got != tt.label {
+			t.Errorf("periodLabel(%s, %+v, %s) = %s, want %s", tt.groupBy, tt.cal, tt.start, got, tt.label)
+		}
+		if got := periodLastDay(tt.groupBy, start).Format("2006-01-02"); got != tt.last {
+			t.Errorf("periodLastDay(%s, %s) = %s, want %s", tt.groupBy, tt.start, got, tt.last)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrRecurringChanged 调度器读取规则后规则已被暂停、删除或修改，本次不再按旧内容入账
+var ErrRecurringChanged = errors.New("周期记账规则已变更")
+
+func (db *DB) migrateRecurring() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS recurring_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			freq TEXT NOT NULL,
+			interval INTEGER NOT NULL DEFAULT 1,
+			start_date TEXT NOT NULL,
+			end_date TEXT NOT NULL DEFAULT '',
+			amount INTEGER NOT NULL, -- 单位：分
+			currency TEXT NOT NULL DEFAULT 'CNY',
+			category_id INTEGER,
+			category TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			account_id INTEGER,
+			active INTEGER NOT NULL DEFAULT 1,
+			seq INTEGER NOT NULL DEFAULT 0,
+			next_date TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_recurring_due ON recurring_rules(active, next_date);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN recurring_rule_id INTEGER`)
+	_, _ = db.conn.Exec(`ALTER TABLE recurring_rules ADD COLUMN version INTEGER NOT NULL DEFAULT 1`)
+	// 同一规则同一日期只生成一条记录，保证重启或重复执行时不会重复入账
+	_, err = db.conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_records_recurring ON records(recurring_rule_id, date) WHERE recurring_rule_id IS NOT NULL`)
+	return err
+}
+
+// IsUniqueViolation 是否为唯一约束冲突
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+const recurringColumns = `id, ledger_id, user_id, name, freq, interval, start_date, end_date, amount, currency,
+	category_id, category, description, account_id, active, seq, next_date, version, created_at, updated_at`
+
+func scanRecurringRule(s rowScanner) (*models.RecurringRule, error) {
+	var r models.RecurringRule
+	var categoryID, accountID sql.NullInt64
+	if err := s.Scan(&r.ID, &r.LedgerID, &r.UserID, &r.Name, &r.Freq, &r.Interval, &r.StartDate, &r.EndDate, &r.Amount, &r.Currency,
+		&categoryID, &r.Category, &r.Description, &accountID, &r.Active, &r.Seq, &r.NextDate, &r.Version, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		r.CategoryID = &categoryID.Int64
+	}
+	if accountID.Valid {
+		r.AccountID = &accountID.Int64
+	}
+	r.RRule = r.FormatRRule()
+	return &r, nil
+}
+
+func (db *DB) queryRecurringRules(where string, args ...interface{}) ([]*models.RecurringRule, error) {
+	rows, err := db.conn.Query(`SELECT `+recurringColumns+` FROM recurring_rules WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.RecurringRule
+	for rows.Next() {
+		r, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// ListRecurringRules 账本内的周期记账规则
+func (db *DB) ListRecurringRules(ledgerID int64) ([]*models.RecurringRule, error) {
+	return db.queryRecurringRules(`ledger_id = ?`, ledgerID)
+}
+
+// DueRecurringRules 所有账本中已到期（next_date 不晚于 today）且未暂停的规则
+func (db *DB) DueRecurringRules(today string) ([]*models.RecurringRule, error) {
+	return db.queryRecurringRules(`active = 1 AND next_date <> '' AND next_date <= ?`, today)
+}
+
+// GetRecurringRule 获取账本内的规则，不存在时返回 nil
+func (db *DB) GetRecurringRule(ledgerID, id int64) (*models.RecurringRule, error) {
+	rows, err := db.queryRecurringRules(`id = ? AND ledger_id = ?`, id, ledgerID)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// CreateRecurringRule 创建规则，首次生成日期为起始日
+func (db *DB) CreateRecurringRule(r *models.RecurringRule) error {
+	r.Seq, r.NextDate = r.Schedule(0, "")
+	res, err := db.conn.Exec(`
+		INSERT INTO recurring_rules (ledger_id, user_id, name, freq, interval, start_date, end_date, amount, currency,
+			category_id, category, description, account_id, active, seq, next_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+		r.LedgerID, r.UserID, r.Name, r.Freq, r.Interval, r.StartDate, r.EndDate, r.Amount, r.Currency,
+		nullID(r.CategoryID), r.Category, r.Description, nullID(r.AccountID), r.Seq, r.NextDate,
+	)
+	if err != nil {
+		return err
+	}
+	r.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// UpdateRecurringRule 更新规则。重新计算下一次生成日期：跳过该规则已生成过的日期，避免频率或起始日变更后重复入账；
+// skipBefore 非空时早于该日的周期也不再补记（用于恢复暂停的规则）。
+// 读取已生成日期与写入规则在同一事务中，版本号递增使调度器中按旧内容进行的补记停止
+func (db *DB) UpdateRecurringRule(ledgerID int64, r *models.RecurringRule, skipBefore string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var last sql.NullString
+	if err := tx.QueryRow(`SELECT MAX(date) FROM records WHERE recurring_rule_id = ?`, r.ID).Scan(&last); err != nil {
+		return err
+	}
+	after := last.String
+	if skipBefore != "" {
+		if d, err := time.Parse("2006-01-02", skipBefore); err == nil && d.AddDate(0, 0, -1).Format("2006-01-02") > after {
+			after = d.AddDate(0, 0, -1).Format("2006-01-02")
+		}
+	}
+	r.Seq, r.NextDate = r.Schedule(0, after)
+	res, err := tx.Exec(`
+		UPDATE recurring_rules SET name=?, freq=?, interval=?, start_date=?, end_date=?, amount=?, currency=?,
+			category_id=?, category=?, description=?, account_id=?, active=?, seq=?, next_date=?, version=version+1, updated_at=CURRENT_TIMESTAMP
+		WHERE id=? AND ledger_id=?`,
+		r.Name, r.Freq, r.Interval, r.StartDate, r.EndDate, r.Amount, r.Currency,
+		nullID(r.CategoryID), r.Category, r.Description, nullID(r.AccountID), r.Active, r.Seq, r.NextDate, r.ID, ledgerID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	r.Version++
+	return tx.Commit()
+}
+
+// PostRecurring 在同一事务中写入规则当前一期（rule.Seq）的记录 r，并将进度推进到第 seq 期、下一次生成日期 next
+// （为空表示规则结束）。仅当规则仍启用且进度与版本号与 rule 一致时才入账，否则返回 ErrRecurringChanged；
+// 该期已入账（如上次推进进度前中断）时只推进进度，created 为 false
+func (db *DB) PostRecurring(rule *models.RecurringRule, r *models.Record, seq int, next string) (created bool, err error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE recurring_rules SET seq=?, next_date=? WHERE id=? AND seq=? AND version=? AND active=1`,
+		seq, next, rule.ID, rule.Seq, rule.Version)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, ErrRecurringChanged
+	}
+	if err := createRecord(tx, r); err != nil {
+		if !IsUniqueViolation(err) {
+			return false, err
+		}
+	} else {
+		created = true
+	}
+	return created, tx.Commit()
+}
+
+// DeleteRecurringRule 删除规则，已生成的记录保留
+func (db *DB) DeleteRecurringRule(ledgerID, id int64) error {
+	res, err := db.conn.Exec(`DELETE FROM recurring_rules WHERE id=? AND ledger_id=?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"errors"
+	"testing"
+)
+
+func recurringRecord(rule *models.RecurringRule) *models.Record {
+	return &models.Record{
+		LedgerID: rule.LedgerID, UserID: rule.UserID, RecurringRuleID: &rule.ID,
+		Date: rule.NextDate, Amount: rule.Amount, Currency: rule.Currency, Category: rule.Category,
+	}
+}
+
+func countRuleRecords(t *testing.T, db *DB, ruleID int64) int {
+	t.Helper()
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM records WHERE recurring_rule_id = ?`, ruleID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPostRecurring(t *testing.T) {
+	db := newTestDB(t)
+	rule := &models.RecurringRule{LedgerID: 1, UserID: 1, Name: "房租", Freq: models.FreqMonthly, Interval: 1,
+		StartDate: "2024-01-31", Amount: -300000, Currency: "CNY", Category: "住房", Active: true}
+	if err := db.CreateRecurringRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := db.GetRecurringRule(1, rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seq, next := stale.Schedule(stale.Seq+1, "")
+	created, err := db.PostRecurring(stale, recurringRecord(stale), seq, next)
+	if err != nil || !created {
+		t.Fatalf("PostRecurring = %v, %v", created, err)
+	}
+	stale.Seq, stale.NextDate = seq, next
+	if got, _ := db.GetRecurringRule(1, rule.ID); got.Seq != 1 || got.NextDate != "2024-02-29" {
+		t.Fatalf("after post: seq=%d next=%s", got.Seq, got.NextDate)
+	}
+
+	// 调度器持有的副本过期后（暂停、改金额）不再入账，也不覆盖新的进度
+	cur, _ := db.GetRecurringRule(1, rule.ID)
+	cur.Active = false
+	if err := db.UpdateRecurringRule(1, cur, ""); err != nil {
+		t.Fatal(err)
+	}
+	seq, next = stale.Schedule(stale.Seq+1, "")
+	if _, err := db.PostRecurring(stale, recurringRecord(stale), seq, next); !errors.Is(err, ErrRecurringChanged) {
+		t.Fatalf("paused rule: err = %v, want ErrRecurringChanged", err)
+	}
+	cur.Active, cur.Amount = true, -350000
+	if err := db.UpdateRecurringRule(1, cur, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PostRecurring(stale, recurringRecord(stale), seq, next); !errors.Is(err, ErrRecurringChanged) {
+		t.Fatalf("modified rule: err = %v, want ErrRecurringChanged", err)
+	}
+	if n := countRuleRecords(t, db, rule.ID); n != 1 {
+		t.Errorf("records = %d, want 1", n)
+	}
+
+	// 重新读取后按新内容入账；同一期已存在时只推进进度
+	fresh, _ := db.GetRecurringRule(1, rule.ID)
+	if fresh.NextDate != "2024-02-29" {
+		t.Fatalf("next_date after update = %s", fresh.NextDate)
+	}
+	if err := db.Create(recurringRecord(fresh)); err != nil {
+		t.Fatal(err)
+	}
+	seq, next = fresh.Schedule(fresh.Seq+1, "")
+	created, err = db.PostRecurring(fresh, recurringRecord(fresh), seq, next)
+	if err != nil || created {
+		t.Fatalf("duplicate period: created=%v err=%v", created, err)
+	}
+	if got, _ := db.GetRecurringRule(1, rule.ID); got.NextDate != "2024-03-31" {
+		t.Errorf("next_date = %s, want 2024-03-31", got.NextDate)
+	}
+
+	if err := db.DeleteRecurringRule(1, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	fresh.Seq, fresh.NextDate = seq, next
+	seq, next = fresh.Schedule(fresh.Seq+1, "")
+	if _, err := db.PostRecurring(fresh, recurringRecord(fresh), seq, next); !errors.Is(err, ErrRecurringChanged) {
+		t.Errorf("deleted rule: err = %v, want ErrRecurringChanged", err)
+	}
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"encoding/json"
+)
+
+func (db *DB) migrateRevisions() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS record_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			record_id INTEGER NOT NULL,
+			ledger_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			revert_of INTEGER,
+			before_data TEXT,
+			after_data TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_record_revisions_record ON record_revisions(record_id, id);
+	`)
+	return err
+}
+
+// revisionMeta 写入修订的操作者与操作类型，RevertOf 为回滚的目标版本（非回滚时为 0）
+type revisionMeta struct {
+	UserID   int64
+	Action   string
+	RevertOf int64
+}
+
+// snapshotRecord 读取记录（含标签与拆分行）的当前版本，记录不存在或在回收站中时返回 nil
+func snapshotRecord(q querier, ledgerID, id int64) (*models.RecordSnapshot, error) {
+	r, err := getRecord(q, ledgerID, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	rows, err := q.Query(`SELECT t.name FROM record_tags rt JOIN tags t ON t.id = rt.tag_id WHERE rt.record_id = ? ORDER BY t.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r.Tags = []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		r.Tags = append(r.Tags, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	splits, err := loadSplits(q, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	r.Splits = splits[id]
+	return models.SnapshotOf(r), nil
+}
+
+// writeRevision 写入一条修订；修改前后没有差异时不写入，返回 nil
+func writeRevision(q querier, ledgerID, recordID int64, meta revisionMeta, before, after *models.RecordSnapshot) (*models.RecordRevision, error) {
+	changes := models.DiffSnapshots(before, after)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	res, err := q.Exec(
+		`INSERT INTO record_revisions (record_id, ledger_id, user_id, action, revert_of, before_data, after_data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		recordID, ledgerID, meta.UserID, meta.Action, nullID(&meta.RevertOf), marshalSnapshot(before), marshalSnapshot(after),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rev := &models.RecordRevision{
+		RecordID: recordID, LedgerID: ledgerID, UserID: meta.UserID, Action: meta.Action,
+		Before: before, After: after, Changes: changes,
+	}
+	rev.ID, _ = res.LastInsertId()
+	if meta.RevertOf != 0 {
+		rev.RevertOf = &meta.RevertOf
+	}
+	return rev, nil
+}
+
+func marshalSnapshot(s *models.RecordSnapshot) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(s)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+func unmarshalSnapshot(data sql.NullString) *models.RecordSnapshot {
+	if !data.Valid {
+		return nil
+	}
+	var s models.RecordSnapshot
+	if err := json.Unmarshal([]byte(data.String), &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// trackRevision 执行 fn 修改记录，并以修改前后的快照写入修订
+func trackRevision(q querier, ledgerID, id int64, meta revisionMeta, fn func() error) (*models.RecordRevision, error) {
+	before, err := snapshotRecord(q, ledgerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	after, err := snapshotRecord(q, ledgerID, id)
+	if err != nil {
+		return nil, err
+	}
+	return writeRevision(q, ledgerID, id, meta, before, after)
+}
+
+// trackRevisions 同 trackRevision，用于一次修改多条记录（如转账的两条分录）
+func trackRevisions(q querier, ledgerID int64, ids []int64, meta revisionMeta, fn func() error) error {
+	before := make([]*models.RecordSnapshot, len(ids))
+	for i, id := range ids {
+		var err error
+		if before[i], err = snapshotRecord(q, ledgerID, id); err != nil {
+			return err
+		}
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		after, err := snapshotRecord(q, ledgerID, id)
+		if err != nil {
+			return err
+		}
+		if _, err := writeRevision(q, ledgerID, id, meta, before[i], after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const revisionSelect = `
+	SELECT v.id, v.record_id, v.ledger_id, v.user_id, COALESCE(u.username, ''), v.action, v.revert_of,
+		v.before_data, v.after_data, v.created_at
+	FROM record_revisions v LEFT JOIN users u ON u.id = v.user_id`
+
+func scanRevision(s rowScanner) (*models.RecordRevision, error) {
+	var v models.RecordRevision
+	var revertOf sql.NullInt64
+	var before, after sql.NullString
+	if err := s.Scan(&v.ID, &v.RecordID, &v.LedgerID, &v.UserID, &v.Username, &v.Action, &revertOf,
+		&before, &after, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	if revertOf.Valid {
+		v.RevertOf = &revertOf.Int64
+	}
+	v.Before, v.After = unmarshalSnapshot(before), unmarshalSnapshot(after)
+	v.Changes = models.DiffSnapshots(v.Before, v.After)
+	return &v, nil
+}
+
+// ListRevisions 记录的修订历史，按时间倒序
+func (db *DB) ListRevisions(ledgerID, recordID int64) ([]*models.RecordRevision, error) {
+	rows, err := db.conn.Query(revisionSelect+` WHERE v.ledger_id = ? AND v.record_id = ? ORDER BY v.id DESC`, ledgerID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*models.RecordRevision{}
+	for rows.Next() {
+		v, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// GetRevision 获取记录的某个修订，不存在时返回 nil
+func (db *DB) GetRevision(ledgerID, recordID, id int64) (*models.RecordRevision, error) {
+	v, err := scanRevision(db.conn.QueryRow(revisionSelect+` WHERE v.id = ? AND v.ledger_id = ? AND v.record_id = ?`, id, ledgerID, recordID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+// RecordExists 记录是否属于该账本（含回收站中的记录）
+func (db *DB) RecordExists(ledgerID, id int64) (bool, error) {
+	var n int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM records WHERE id = ? AND ledger_id = ?`, id, ledgerID).Scan(&n)
+	return n > 0, err
+}
+
+// Revert 按修订快照整体更新记录，写入 revert 类型的修订。version 含义同 Update
+func (db *DB) Revert(ledgerID, id, revisionID, userID, version int64, req *models.UpdateRecordRequest) (*models.RecordRevision, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rev, err := updateRecord(tx, ledgerID, id, version, req, revisionMeta{UserID: userID, Action: models.RevisionRevert, RevertOf: revisionID})
+	if err != nil {
+		return nil, err
+	}
+	return rev, tx.Commit()
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// migrateSearch 为记录的描述、分类与交易对方建立 FTS5 全文索引（trigram 分词，支持中文子串匹配），由触发器与 records 保持同步
+func (db *DB) migrateSearch() error {
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN payee TEXT NOT NULL DEFAULT ''`)
+	var exists int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'records_fts'`).Scan(&exists); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS records_fts USING fts5(
+			description, category, payee,
+			content='records', content_rowid='id', tokenize='trigram'
+		);
+		CREATE TRIGGER IF NOT EXISTS records_fts_ai AFTER INSERT ON records BEGIN
+			INSERT INTO records_fts(rowid, description, category, payee) VALUES (new.id, new.description, new.category, new.payee);
+		END;
+		CREATE TRIGGER IF NOT EXISTS records_fts_ad AFTER DELETE ON records BEGIN
+			INSERT INTO records_fts(records_fts, rowid, description, category, payee) VALUES ('delete', old.id, old.description, old.category, old.payee);
+		END;
+		CREATE TRIGGER IF NOT EXISTS records_fts_au AFTER UPDATE OF description, category, payee ON records BEGIN
+			INSERT INTO records_fts(records_fts, rowid, description, category, payee) VALUES ('delete', old.id, old.description, old.category, old.payee);
+			INSERT INTO records_fts(rowid, description, category, payee) VALUES (new.id, new.description, new.category, new.payee);
+		END;
+	`)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		// 首次建立索引时导入已有记录
+		_, err = db.conn.Exec(`INSERT INTO records_fts(records_fts) VALUES ('rebuild')`)
+	}
+	return err
+}
+
+// searchQuery 解析后的关键字：match 为 FTS5 查询串；likes 为不足三个字符、trigram 无法索引的词，改用 LIKE 匹配
+type searchQuery struct {
+	match string
+	likes []string
+}
+
+// parseSearch 解析关键字。空白分隔多个词（须同时命中），双引号括起的部分作为整体短语，
+// 词尾的 * 表示前缀（trigram 本身按子串匹配，前缀与子串等价）
+func parseSearch(keyword string) searchQuery {
+	var terms []string
+	rest := strings.TrimSpace(keyword)
+	for rest != "" {
+		var term string
+		if rest[0] == '"' {
+			if end := strings.IndexByte(rest[1:], '"'); end >= 0 {
+				term, rest = rest[1:end+1], rest[end+2:]
+			} else {
+				term, rest = rest[1:], ""
+			}
+		} else if i := strings.IndexAny(rest, " \t　"); i >= 0 {
+			term, rest = rest[:i], rest[i:]
+		} else {
+			term, rest = rest, ""
+		}
+		rest = strings.TrimLeft(rest, " \t　")
+		if term = strings.TrimSpace(strings.TrimSuffix(term, "*")); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	var q searchQuery
+	var match []string
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) < 3 {
+			q.likes = append(q.likes, t)
+			continue
+		}
+		match = append(match, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+	}
+	q.match = strings.Join(match, " ")
+	return q
+}
+
+// escapeLike 转义 LIKE 通配符，配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// snippetMarker 替换 snippet() 以控制字符标记的命中位置：先对片段整体做 HTML 转义，再换成 <mark> 标签，
+// 避免描述、交易对方中的 HTML 原样输出
+var snippetMarker = strings.NewReplacer("\x01", "<mark>", "\x02", "</mark>")
+
+// highlightSnippet 将 FTS5 返回的片段转为可直接展示的 HTML
+func highlightSnippet(s string) string {
+	return snippetMarker.Replace(html.EscapeString(s))
+}
+
+// extraScanner 在 scanRecord 的列之后追加扫描额外的列（如搜索片段、相关度）
+type extraScanner struct {
+	rows  *sql.Rows
+	extra []interface{}
+}
+
+func (e extraScanner) Scan(dest ...interface{}) error {
+	return e.rows.Scan(append(dest, e.extra...)...)
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		in    string
+		match string
+		likes []string
+	}{
+		{"", "", nil},
+		{"   ", "", nil},
+		{"星巴克", `"星巴克"`, nil},
+		{"午饭", "", []string{"午饭"}}, // 不足三个字符，trigram 无法索引
+		{"a", "", []string{"a"}},
+		{"coffee shop", `"coffee" "shop"`, nil},
+		{"早餐　咖啡店", `"咖啡店"`, []string{"早餐"}}, // 全角空格分隔
+		{"coffee\tshop", `"coffee" "shop"`, nil},
+		{`"coffee shop"`, `"coffee shop"`, nil},
+		{`"coffee shop`, `"coffee shop"`, nil}, // 未闭合的引号取到末尾
+		{`"  "`, "", nil},
+		{`say"hi"`, `"say""hi"""`, nil}, // 词中的引号按 FTS5 规则转义
+		{`"a" "bc" "def"`, `"def"`, []string{"a", "bc"}},
+		// FTS5 运算符与语法字符一律作为普通文本
+		{"tea OR coffee", `"tea" "coffee"`, []string{"OR"}},
+		{"AND NOT", `"AND" "NOT"`, nil},
+		{"NEAR(abc def)", `"NEAR(abc" "def)"`, nil},
+		{"desc:abc", `"desc:abc"`, nil},
+		{"-abc ^abc +abc", `"-abc" "^abc" "+abc"`, nil},
+		{"abc*", `"abc"`, nil},
+		{"ab*", "", []string{"ab"}},
+		{"*", "", nil},
+		{"50%_off", `"50%_off"`, nil},
+	}
+	for _, tt := range tests {
+		q := parseSearch(tt.in)
+		if q.match != tt.match || !reflect.DeepEqual(q.likes, tt.likes) {
+			t.Errorf("parseSearch(%q) = {%q, %q}, want {%q, %q}", tt.in, q.match, q.likes, tt.match, tt.likes)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"abc", "abc"},
+		{"50%", `50\%`},
+		{"a_b", `a\_b`},
+		{`C:\tmp`, `C:\\tmp`},
+		{`%_\`, `\%\_\\`},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.in); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSearchKeywords 含 FTS5 语法字符的关键字不应导致查询出错，且按子串命中
+func TestSearchKeywords(t *testing.T) {
+	db := newTestDB(t)
+	for _, desc := range []string{`say "hi" to NEAR(abc)`, "星巴克咖啡", "50%_off coffee"} {
+		r := &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-01", Amount: -100, Currency: "CNY", Description: desc}
+		if err := db.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		keyword string
+		want    int64
+	}{
+		{`say"hi"`, 0},
+		{`"say ""hi"""`, 1}, // 拆为 say、hi 两个词
+		{`"hi" to`, 1},
+		{"NEAR(abc", 1},
+		{"AND NOT", 0},
+		{"星巴克", 1},
+		{"咖啡", 1},
+		{"巴克咖", 1},
+		{"50%", 1},
+		{"%", 1}, // LIKE 通配符按字面匹配
+		{"_", 1},
+		{"%%", 0},
+		{"coff*", 1},
+		{"desc:abc", 0},
+	}
+	for _, tt := range tests {
+		params := &models.QueryParams{Keyword: tt.keyword}
+		if err := params.Validate(); err != nil {
+			t.Fatalf("Validate(%q): %v", tt.keyword, err)
+		}
+		_, total, _, err := db.List(1, params)
+		if err != nil {
+			t.Errorf("List(keyword=%q): %v", tt.keyword, err)
+			continue
+		}
+		if total != tt.want {
+			t.Errorf("List(keyword=%q) total = %d, want %d", tt.keyword, total, tt.want)
+		}
+	}
+}
+
+// TestSearchSnippetEscaped 高亮片段中记录自身的文本须经 HTML 转义，仅命中部分包裹 <mark>
+func TestSearchSnippetEscaped(t *testing.T) {
+	db := newTestDB(t)
+	r := &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-01", Amount: -100, Currency: "CNY",
+		Description: `<b>coffee</b>&`}
+	if err := db.Create(r); err != nil {
+		t.Fatal(err)
+	}
+	params := &models.QueryParams{Keyword: "coffee"}
+	if err := params.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	list, _, _, err := db.List(1, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("List(keyword=coffee) = %d records, want 1", len(list))
+	}
+	want := `&lt;b&gt;<mark>coffee</mark>&lt;/b&gt;&amp;`
+	if list[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", list[0].Snippet, want)
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"errors"
+)
+
+// ErrSplitMismatch 拆分行合计与记录金额不一致
+var ErrSplitMismatch = errors.New("拆分合计与记录金额不一致，修改金额时请同时修改 splits")
+
+// migrateSplits 拆分行：分类同 records 保存 ID 与名称，按 position 保持请求中的顺序
+func (db *DB) migrateSplits() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS record_splits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			record_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			category_id INTEGER,
+			category TEXT NOT NULL DEFAULT '',
+			amount INTEGER NOT NULL,
+			note TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_record_splits_record ON record_splits(record_id, position);
+		CREATE INDEX IF NOT EXISTS idx_record_splits_category ON record_splits(category_id);
+	`)
+	return err
+}
+
+// loadSplits 读取多条记录的拆分行，按记录 ID 分组
+func loadSplits(q querier, ids []int64) (map[int64][]*models.Split, error) {
+	byID := map[int64][]*models.Split{}
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := q.Query(`SELECT record_id, category_id, category, amount, note FROM record_splits
+		WHERE record_id IN (`+placeholders(len(ids))+`) ORDER BY record_id, position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var s models.Split
+		if err := rows.Scan(&id, &s.CategoryID, &s.Category, &s.Amount, &s.Note); err != nil {
+			return nil, err
+		}
+		byID[id] = append(byID[id], &s)
+	}
+	return byID, rows.Err()
+}
+
+// attachSplits 为记录列表填充拆分行
+func (db *DB) attachSplits(list []*models.Record) error {
+	ids := make([]int64, len(list))
+	for i, r := range list {
+		ids[i] = r.ID
+	}
+	byID, err := loadSplits(db.conn, ids)
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		r.Splits = byID[r.ID]
+	}
+	return nil
+}
+
+// setRecordSplits 整体替换记录的拆分行，splits 为空时取消拆分。
+// 只给出名称的分类在此查找或按金额正负创建，便于批量操作在事务内创建
+func setRecordSplits(q querier, ledgerID, recordID int64, splits []*models.Split) error {
+	if _, err := q.Exec(`DELETE FROM record_splits WHERE record_id = ?`, recordID); err != nil {
+		return err
+	}
+	for i, s := range splits {
+		if (s.CategoryID == nil || *s.CategoryID == 0) && s.Category != "" {
+			typ := models.CategoryTypeExpense
+			if s.Amount > 0 {
+				typ = models.CategoryTypeIncome
+			}
+			cat, err := findOrCreateCategory(q, ledgerID, s.Category, typ)
+			if err != nil {
+				return err
+			}
+			s.CategoryID, s.Category = &cat.ID, cat.Name
+		}
+		if _, err := q.Exec(`INSERT INTO record_splits (record_id, position, category_id, category, amount, note) VALUES (?, ?, ?, ?, ?, ?)`,
+			recordID, i, nullID(s.CategoryID), s.Category, s.Amount, s.Note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSplits 校验记录的拆分行合计等于记录金额（未拆分的记录不校验），不一致时返回 ErrSplitMismatch
+func checkSplits(q querier, recordID int64) error {
+	var n int
+	var amount, sum models.Money
+	err := q.QueryRow(`SELECT r.amount, COUNT(s.id), COALESCE(SUM(s.amount), 0)
+		FROM records r LEFT JOIN record_splits s ON s.record_id = r.id WHERE r.id = ? GROUP BY r.id`, recordID).Scan(&amount, &n, &sum)
+	if err != nil {
+		return err
+	}
+	if n > 0 && sum != amount {
+		return ErrSplitMismatch
+	}
+	return nil
+}
@@ -3,237 +3,318 @@ package database
 import (
 	"account-service/internal/models"
 	"database/sql"
-	"fmt"
+	"sort"
+	"strings"
 )
 
-// DailySummary 某日汇总
-func (db *DB) DailySummary(date string) (*models.Summary, error) {
-	var income, expense sql.NullFloat64
-	var cnt int
-	err := db.conn.QueryRow(`
-		SELECT 
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date = ?
-	`, date).Scan(&income, &expense, &cnt)
+// 汇总与报表中的收支统计均排除转账分录（transfer_id 非空），转账只影响账户余额；
+// 拆分记录按拆分行计入各自分类，不再计入记录本身；
+// 金额按记录日期的汇率换算为查询用户的本位币，原币种合计见 ByCurrency。
+
+// rateExpr 记录币种兑本位币的汇率：优先取记录日期当天或之前最近的汇率（含反向汇率），
+// 都没有时取之后最近的汇率；仍无可用汇率时为 NULL。占位参数依次为 4 个本位币。
+const rateExpr = `COALESCE(
+	(SELECT x.rate FROM exchange_rates x WHERE x.from_currency = r.currency AND x.to_currency = ? AND x.date <= r.date ORDER BY x.date DESC LIMIT 1),
+	(SELECT 1.0 / x.rate FROM exchange_rates x WHERE x.from_currency = ? AND x.to_currency = r.currency AND x.date <= r.date ORDER BY x.date DESC LIMIT 1),
+	(SELECT x.rate FROM exchange_rates x WHERE x.from_currency = r.currency AND x.to_currency = ? AND x.date > r.date ORDER BY x.date LIMIT 1),
+	(SELECT 1.0 / x.rate FROM exchange_rates x WHERE x.from_currency = ? AND x.to_currency = r.currency AND x.date > r.date ORDER BY x.date LIMIT 1))`
+
+// statsCTE 生成名为 stats 的 CTE：账本内日期范围的非转账记录（不含回收站），拆分记录展开为每个拆分行一行
+// （id 仍为记录 ID，统计记录数须用 COUNT(DISTINCT s.id)）。category、category_id 与 amount 取拆分行的值，
+// base_amount 为换算后的本位币金额（分），无可用汇率时为 NULL；top_category_id 为所属的一级分类。
+// cond 为附加筛选条件（可引用 r.*、c.*，c 为拆分行或记录的分类），为空时不筛选
+func statsCTE(ledgerID int64, start, end, base, cond string, condArgs ...interface{}) (string, []interface{}) {
+	where := `r.ledger_id = ? AND r.deleted_at IS NULL AND r.transfer_id IS NULL AND r.date >= ? AND r.date <= ?`
+	if cond != "" {
+		where += ` AND (` + cond + `)`
+	}
+	cte := `
+		WITH stats AS (
+			SELECT r.id, r.date,
+				CASE WHEN sp.id IS NULL THEN r.category ELSE sp.category END AS category,
+				CASE WHEN sp.id IS NULL THEN r.category_id ELSE sp.category_id END AS category_id,
+				COALESCE(c.parent_id, c.id) AS top_category_id,
+				r.account_id, r.payee_id, r.currency, COALESCE(sp.amount, r.amount) AS amount,
+				CASE WHEN r.currency = ? THEN COALESCE(sp.amount, r.amount)
+					ELSE CAST(ROUND(COALESCE(sp.amount, r.amount) * ` + rateExpr + `) AS INTEGER) END AS base_amount
+			FROM records r
+			LEFT JOIN record_splits sp ON sp.record_id = r.id
+			LEFT JOIN categories c ON c.id = CASE WHEN sp.id IS NULL THEN r.category_id ELSE sp.category_id END
+			WHERE ` + where + `
+		)`
+	args := []interface{}{base, base, base, base, base, ledgerID, start, end}
+	return cte, append(args, condArgs...)
+}
+
+// sumColumns 本位币收入、支出与记录数（stats 须以 s 为别名）
+const sumColumns = `
+	COALESCE(SUM(CASE WHEN base_amount > 0 THEN base_amount ELSE 0 END), 0),
+	COALESCE(ABS(SUM(CASE WHEN base_amount < 0 THEN base_amount ELSE 0 END)), 0),
+	COUNT(DISTINCT s.id)`
+
+// summarize 统计总收支、缺少汇率的记录数及原币种分项
+func (db *DB) summarize(cte string, args []interface{}, base string) (*models.Summary, error) {
+	s := &models.Summary{Currency: base}
+	err := db.conn.QueryRow(cte+`
+		SELECT `+sumColumns+`, COUNT(DISTINCT CASE WHEN base_amount IS NULL THEN s.id END) FROM stats s
+	`, args...).Scan(&s.Income, &s.Expense, &s.Count, &s.Unconverted)
 	if err != nil {
 		return nil, err
 	}
-	s := &models.Summary{
-		Income:  floatVal(income),
-		Expense: floatVal(expense),
-		Balance: floatVal(income) - floatVal(expense),
-		Count:   cnt,
-	}
-	// 明细
-	rows, err := db.conn.Query(
-		`SELECT id, date, amount, category, description, created_at, updated_at 
-		 FROM records WHERE date = ? ORDER BY id`,
-		date,
-	)
+	s.Balance = s.Income - s.Expense
+	s.ByCurrency, _ = db.currencyBreakdown(cte, args)
+	return s, nil
+}
+
+// Summary 汇总引擎：q 指定日期范围内的总收支及按账户分项，q.GroupBy 非空时按日/周/月/季度/年分项
+func (db *DB) Summary(ledgerID int64, q models.SummaryQuery, base string) (*models.Summary, error) {
+	cte, args := statsCTE(ledgerID, q.StartDate, q.EndDate, base, "")
+	s, err := db.summarize(cte, args, base)
 	if err != nil {
-		return s, nil
+		return nil, err
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var r models.Record
-		if err := rows.Scan(&r.ID, &r.Date, &r.Amount, &r.Category, &r.Description, &r.CreatedAt, &r.UpdatedAt); err != nil {
-			break
-		}
-		s.Records = append(s.Records, &r)
+	s.StartDate, s.EndDate = q.StartDate, q.EndDate
+	if q.GroupBy != "" {
+		s.Breakdown, _ = db.periodBreakdown(cte, args, q.GroupBy, q.Calendar, q.StartDate, q.EndDate)
 	}
+	s.ByAccount, _ = db.accountBreakdown(cte, args)
 	return s, nil
 }
 
-// MonthlySummary 某月汇总
-func (db *DB) MonthlySummary(year, month int) (*models.Summary, error) {
-	start := fmtDate(year, month, 1)
-	end := fmtDate(year, month, daysInMonth(year, month))
-
-	var income, expense sql.NullFloat64
-	var cnt int
-	err := db.conn.QueryRow(`
-		SELECT 
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-	`, start, end).Scan(&income, &expense, &cnt)
+// DailySummary 某日汇总，附当日明细
+func (db *DB) DailySummary(ledgerID int64, date, base string) (*models.Summary, error) {
+	s, err := db.Summary(ledgerID, models.SummaryQuery{StartDate: date, EndDate: date}, base)
 	if err != nil {
 		return nil, err
 	}
-	s := &models.Summary{
-		Income:  floatVal(income),
-		Expense: floatVal(expense),
-		Balance: floatVal(income) - floatVal(expense),
-		Count:   cnt,
-	}
-	// 按日分项
-	rows, err := db.conn.Query(`
-		SELECT date,
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-		GROUP BY date ORDER BY date
-	`, start, end)
+	// 明细
+	rows, err := db.conn.Query(
+		`SELECT `+recordColumns+` FROM records WHERE ledger_id = ? AND deleted_at IS NULL AND transfer_id IS NULL AND date = ? ORDER BY id`,
+		ledgerID, date,
+	)
 	if err != nil {
 		return s, nil
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var item models.BreakdownItem
-		var inc, exp sql.NullFloat64
-		if err := rows.Scan(&item.Period, &inc, &exp, &item.Count); err != nil {
+		r, err := scanRecord(rows)
+		if err != nil {
 			break
 		}
-		item.Income = floatVal(inc)
-		item.Expense = floatVal(exp)
-		item.Balance = item.Income - item.Expense
-		s.Breakdown = append(s.Breakdown, &item)
+		s.Records = append(s.Records, r)
 	}
+	_ = db.attachTags(s.Records)
+	_ = db.attachSplits(s.Records)
 	return s, nil
 }
 
-// YearlySummary 某年汇总
-func (db *DB) YearlySummary(year int) (*models.Summary, error) {
-	start := fmtDate(year, 1, 1)
-	end := fmtDate(year, 12, 31)
+// MonthlySummary 某月汇总（按 cal 的每月起始日划分），按日分项
+func (db *DB) MonthlySummary(ledgerID int64, year, month int, cal models.Calendar, base string) (*models.Summary, error) {
+	start, end := cal.MonthRange(year, month)
+	return db.Summary(ledgerID, models.SummaryQuery{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+		GroupBy:   models.GroupByDay,
+		Calendar:  cal,
+	}, base)
+}
 
-	var income, expense sql.NullFloat64
-	var cnt int
-	err := db.conn.QueryRow(`
-		SELECT 
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-	`, start, end).Scan(&income, &expense, &cnt)
+// YearlySummary 某财年汇总（按 cal 的财年起始月与每月起始日划分），按月分项
+func (db *DB) YearlySummary(ledgerID int64, year int, cal models.Calendar, base string) (*models.Summary, error) {
+	start, end := cal.YearRange(year)
+	return db.Summary(ledgerID, models.SummaryQuery{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+		GroupBy:   models.GroupByMonth,
+		Calendar:  cal,
+	}, base)
+}
+
+// DailyTotals 日期范围内按日的收支（本位币），没有记录的日期不返回；
+// excludeRecurring 为 true 时不含周期记账生成的记录，用于估计日常收支。categoryID 非 0 时仅统计该分类及其子分类
+func (db *DB) DailyTotals(ledgerID int64, startDate, endDate, base string, excludeRecurring bool, categoryID int64) ([]*models.BreakdownItem, error) {
+	var conds []string
+	var condArgs []interface{}
+	if excludeRecurring {
+		conds = append(conds, "r.recurring_rule_id IS NULL")
+	}
+	if categoryID != 0 {
+		conds = append(conds, "(c.id = ? OR c.parent_id = ?)")
+		condArgs = append(condArgs, categoryID, categoryID)
+	}
+	cte, args := statsCTE(ledgerID, startDate, endDate, base, strings.Join(conds, " AND "), condArgs...)
+	return db.periodBreakdown(cte, args, models.GroupByDay, models.Calendar{}, startDate, endDate)
+}
+
+// Report 报表：指定日期范围内的汇总及分项，按月分项按 q.Calendar 划分。q.CategoryID 非 0 时下钻到该分类：
+// 仅统计该分类及其子分类的记录，按分类统计列出其子分类。q.Compare 非空时附带与对比区间的比较
+func (db *DB) Report(ledgerID int64, q models.ReportQuery, base string) (*models.Report, error) {
+	startDate, endDate, categoryID, cal := q.StartDate, q.EndDate, q.CategoryID, q.Calendar
+	cond, condArgs := "", []interface{}{}
+	if categoryID != 0 {
+		cond, condArgs = "c.id = ? OR c.parent_id = ?", []interface{}{categoryID, categoryID}
+	}
+	cte, args := statsCTE(ledgerID, startDate, endDate, base, cond, condArgs...)
+	s, err := db.summarize(cte, args, base)
 	if err != nil {
 		return nil, err
 	}
-	s := &models.Summary{
-		Income:  floatVal(income),
-		Expense: floatVal(expense),
-		Balance: floatVal(income) - floatVal(expense),
-		Count:   cnt,
+	r := &models.Report{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Currency:    base,
+		Income:      s.Income,
+		Expense:     s.Expense,
+		Balance:     s.Balance,
+		Count:       s.Count,
+		Unconverted: s.Unconverted,
+		ByCurrency:  s.ByCurrency,
 	}
-	// 按月分项
-	rows, err := db.conn.Query(`
-		SELECT strftime('%Y-%m', date) as month,
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-		GROUP BY month ORDER BY month
-	`, start, end)
-	if err != nil {
-		return s, nil
+	// 按日
+	r.Daily, _ = db.periodBreakdown(cte, args, models.GroupByDay, cal, startDate, endDate)
+	// 按月
+	r.Monthly, _ = db.periodBreakdown(cte, args, models.GroupByMonth, cal, startDate, endDate)
+
+	// 按分类：一级分类汇总其子分类；下钻时直接按子分类列出
+	r.ByCategory, _ = db.categoryBreakdown(cte, args, categoryID == 0)
+	if categoryID != 0 {
+		r.CategoryID = &categoryID
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var item models.BreakdownItem
-		var inc, exp sql.NullFloat64
-		if err := rows.Scan(&item.Period, &inc, &exp, &item.Count); err != nil {
-			break
+	// 按标签
+	r.ByTag, _ = db.tagBreakdown(cte, args)
+	// 按交易对方
+	r.ByPayee, _ = db.payeeBreakdown(cte, args)
+
+	if q.Compare != "" {
+		if r.Compare, err = db.compareReport(r, ledgerID, q, base, cond, condArgs); err != nil {
+			return nil, err
 		}
-		item.Income = floatVal(inc)
-		item.Expense = floatVal(exp)
-		item.Balance = item.Income - item.Expense
-		s.Breakdown = append(s.Breakdown, &item)
 	}
-	return s, nil
+	return r, nil
 }
 
-// Report 报表：指定日期范围内的汇总及分项
-func (db *DB) Report(startDate, endDate string) (*models.Report, error) {
-	r := &models.Report{StartDate: startDate, EndDate: endDate}
-
-	var income, expense sql.NullFloat64
-	var cnt int
-	err := db.conn.QueryRow(`
-		SELECT 
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-	`, startDate, endDate).Scan(&income, &expense, &cnt)
+// categoryBreakdown 按分类分项。rollup 为 true 时按一级分类汇总，子分类明细放入 Children；
+// 否则按记录所属分类逐项列出。均按合计绝对值降序
+func (db *DB) categoryBreakdown(cte string, args []interface{}, rollup bool) ([]*models.CategoryItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT s.top_category_id, COALESCE(t.name, ?), s.category_id, COALESCE(c.name, ?), `+sumColumns+`, COALESCE(SUM(s.base_amount), 0)
+		FROM stats s
+		LEFT JOIN categories t ON t.id = s.top_category_id
+		LEFT JOIN categories c ON c.id = s.category_id
+		GROUP BY s.top_category_id, s.category_id
+		ORDER BY ABS(SUM(s.base_amount)) DESC
+	`, append(args, models.UncategorizedName, models.UncategorizedName)...)
 	if err != nil {
 		return nil, err
 	}
-	r.Income = floatVal(income)
-	r.Expense = floatVal(expense)
-	r.Balance = r.Income - r.Expense
-	r.Count = cnt
-
-	// 按日
-	rows, _ := db.conn.Query(`
-		SELECT date,
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-		GROUP BY date ORDER BY date
-	`, startDate, endDate)
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var item models.BreakdownItem
-			var inc, exp sql.NullFloat64
-			if err := rows.Scan(&item.Period, &inc, &exp, &item.Count); err != nil {
-				break
+	defer rows.Close()
+	var list []*models.CategoryItem
+	tops := map[int64]*models.CategoryItem{}
+	for rows.Next() {
+		var topID, catID sql.NullInt64
+		var topName string
+		item := &models.CategoryItem{}
+		if err := rows.Scan(&topID, &topName, &catID, &item.Category, &item.Income, &item.Expense, &item.Count, &item.Total); err != nil {
+			return nil, err
+		}
+		if catID.Valid {
+			item.CategoryID = &catID.Int64
+		}
+		if !rollup {
+			list = append(list, item)
+			continue
+		}
+		top, ok := tops[topID.Int64]
+		if !ok {
+			top = &models.CategoryItem{Category: topName}
+			if topID.Valid {
+				top.CategoryID = &topID.Int64
 			}
-			item.Income = floatVal(inc)
-			item.Expense = floatVal(exp)
-			item.Balance = item.Income - item.Expense
-			r.Daily = append(r.Daily, &item)
+			tops[topID.Int64] = top
+			list = append(list, top)
 		}
+		top.Income += item.Income
+		top.Expense += item.Expense
+		top.Total += item.Total
+		top.Count += item.Count
+		top.Children = append(top.Children, item)
 	}
-
-	// 按分类
-	catRows, _ := db.conn.Query(`
-		SELECT COALESCE(category, '未分类') as cat,
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
-			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
-			SUM(amount),
-			COUNT(*)
-		FROM records WHERE date >= ? AND date <= ?
-		GROUP BY cat ORDER BY ABS(SUM(amount)) DESC
-	`, startDate, endDate)
-	if catRows != nil {
-		defer catRows.Close()
-		for catRows.Next() {
-			var item models.CategoryItem
-			var inc, exp, total sql.NullFloat64
-			if err := catRows.Scan(&item.Category, &inc, &exp, &total, &item.Count); err != nil {
-				break
-			}
-			item.Income = floatVal(inc)
-			item.Expense = floatVal(exp)
-			item.Total = floatVal(total)
-			r.ByCategory = append(r.ByCategory, &item)
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// 仅有直接记在一级分类下的记录时不再展开
+	for _, top := range list {
+		if len(top.Children) == 1 && sameID(top.Children[0].CategoryID, top.CategoryID) {
+			top.Children = nil
 		}
 	}
-
-	return r, nil
+	if rollup {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Total.Abs() > list[j].Total.Abs() })
+	}
+	return list, nil
 }
 
-func floatVal(n sql.NullFloat64) float64 {
-	if n.Valid {
-		return n.Float64
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	return 0
+	return *a == *b
 }
 
-func fmtDate(y, m, d int) string {
-	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+// accountBreakdown 按账户分项
+func (db *DB) accountBreakdown(cte string, args []interface{}) ([]*models.AccountItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT s.account_id, COALESCE(a.name, '未指定账户'),
+			COALESCE(SUM(CASE WHEN s.base_amount > 0 THEN s.base_amount ELSE 0 END), 0),
+			COALESCE(ABS(SUM(CASE WHEN s.base_amount < 0 THEN s.base_amount ELSE 0 END)), 0),
+			COUNT(DISTINCT s.id)
+		FROM stats s LEFT JOIN accounts a ON a.id = s.account_id
+		GROUP BY s.account_id ORDER BY s.account_id IS NULL, s.account_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.AccountItem
+	for rows.Next() {
+		var item models.AccountItem
+		var accountID sql.NullInt64
+		if err := rows.Scan(&accountID, &item.Account, &item.Income, &item.Expense, &item.Count); err != nil {
+			return nil, err
+		}
+		if accountID.Valid {
+			item.AccountID = &accountID.Int64
+		}
+		item.Balance = item.Income - item.Expense
+		list = append(list, &item)
+	}
+	return list, nil
 }
 
-func daysInMonth(year, month int) int {
-	days := []int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
-	if month == 2 && (year%4 == 0 && (year%100 != 0 || year%400 == 0)) {
-		return 29
+// currencyBreakdown 按原币种分项：原币金额及换算后的本位币金额
+func (db *DB) currencyBreakdown(cte string, args []interface{}) ([]*models.CurrencyItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT currency,
+			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0),
+			COALESCE(ABS(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)), 0),
+			`+sumColumns+`, COUNT(DISTINCT CASE WHEN base_amount IS NULL THEN s.id END)
+		FROM stats s
+		GROUP BY currency ORDER BY currency
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.CurrencyItem
+	for rows.Next() {
+		var item models.CurrencyItem
+		if err := rows.Scan(&item.Currency, &item.Income, &item.Expense,
+			&item.ConvertedIncome, &item.ConvertedExpense, &item.Count, &item.Unconverted); err != nil {
+			return nil, err
+		}
+		item.Balance = item.Income - item.Expense
+		item.ConvertedBalance = item.ConvertedIncome - item.ConvertedExpense
+		list = append(list, &item)
 	}
-	return days[month-1]
+	return list, nil
 }
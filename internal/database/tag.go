@@ -0,0 +1,160 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func (db *DB) migrateTags() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (ledger_id, name)
+		);
+		CREATE TABLE IF NOT EXISTS record_tags (
+			record_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (record_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_record_tags_tag ON record_tags(tag_id);
+	`)
+	return err
+}
+
+// setRecordTags 以 names 整体替换记录的标签，不存在的标签自动创建
+func setRecordTags(q querier, ledgerID, recordID int64, names []string) error {
+	if _, err := q.Exec(`DELETE FROM record_tags WHERE record_id = ?`, recordID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := q.Exec(`INSERT INTO tags (ledger_id, name) VALUES (?, ?) ON CONFLICT DO NOTHING`, ledgerID, name); err != nil {
+			return err
+		}
+		if _, err := q.Exec(`
+			INSERT OR IGNORE INTO record_tags (record_id, tag_id)
+			SELECT ?, id FROM tags WHERE ledger_id = ? AND name = ?
+		`, recordID, ledgerID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachTags 为记录批量加载标签
+func (db *DB) attachTags(list []*models.Record) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.Record, len(list))
+	args := make([]interface{}, 0, len(list))
+	for _, r := range list {
+		r.Tags = []string{}
+		byID[r.ID] = r
+		args = append(args, r.ID)
+	}
+	rows, err := db.conn.Query(`
+		SELECT rt.record_id, t.name FROM record_tags rt JOIN tags t ON t.id = rt.tag_id
+		WHERE rt.record_id IN (`+placeholders(len(args))+`) ORDER BY t.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+	return rows.Err()
+}
+
+// tagCond 生成按标签筛选记录的条件，col 为记录 ID 列；all 为 true 时须含全部标签，否则含任一标签
+func tagCond(col string, ledgerID int64, names []string, all bool) (string, []interface{}) {
+	args := []interface{}{ledgerID}
+	for _, n := range names {
+		args = append(args, n)
+	}
+	cond := col + ` IN (SELECT rt.record_id FROM record_tags rt JOIN tags t ON t.id = rt.tag_id
+		WHERE t.ledger_id = ? AND t.name IN (` + placeholders(len(names)) + `)`
+	if all {
+		cond += fmt.Sprintf(` GROUP BY rt.record_id HAVING COUNT(DISTINCT t.id) = %d`, len(names))
+	}
+	return cond + `)`, args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// ListTags 账本内的标签及关联记录数
+func (db *DB) ListTags(ledgerID int64) ([]*models.Tag, error) {
+	rows, err := db.conn.Query(`
+		SELECT t.id, t.ledger_id, t.name, COUNT(r.id), t.created_at
+		FROM tags t LEFT JOIN record_tags rt ON rt.tag_id = t.id
+			LEFT JOIN records r ON r.id = rt.record_id AND r.deleted_at IS NULL
+		WHERE t.ledger_id = ?
+		GROUP BY t.id ORDER BY t.name
+	`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.LedgerID, &t.Name, &t.Count, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &t)
+	}
+	return list, nil
+}
+
+// DeleteTag 删除标签及其与记录的关联，记录本身保留
+func (db *DB) DeleteTag(ledgerID, id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM tags WHERE id = ? AND ledger_id = ?`, id, ledgerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM record_tags WHERE tag_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tagBreakdown 按标签分项，按合计绝对值降序
+func (db *DB) tagBreakdown(cte string, args []interface{}) ([]*models.TagItem, error) {
+	rows, err := db.conn.Query(cte+`
+		SELECT t.id, t.name, `+sumColumns+`, COALESCE(SUM(base_amount), 0)
+		FROM stats s JOIN record_tags rt ON rt.record_id = s.id JOIN tags t ON t.id = rt.tag_id
+		GROUP BY t.id ORDER BY ABS(SUM(base_amount)) DESC, t.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.TagItem
+	for rows.Next() {
+		var item models.TagItem
+		if err := rows.Scan(&item.TagID, &item.Tag, &item.Income, &item.Expense, &item.Count, &item.Total); err != nil {
+			return nil, err
+		}
+		list = append(list, &item)
+	}
+	return list, nil
+}
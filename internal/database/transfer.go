@@ -0,0 +1,225 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+)
+
+// ErrTransferLeg 转账分录只能通过转账接口整体修改
+var ErrTransferLeg = errors.New("转账记录请通过 /api/transfers 修改")
+
+func (db *DB) migrateTransfers() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS transfers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ledger_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			date TEXT NOT NULL,
+			description TEXT,
+			out_record_id INTEGER NOT NULL,
+			in_record_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_transfers_ledger ON transfers(ledger_id, date);
+	`)
+	if err != nil {
+		return err
+	}
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN transfer_id INTEGER`)
+	_, _ = db.conn.Exec(`ALTER TABLE transfers ADD COLUMN version INTEGER NOT NULL DEFAULT 1`)
+	_, _ = db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_transfer ON records(transfer_id)`)
+	return nil
+}
+
+// CreateTransfer 在同一事务中写入转账及其两条分录
+func (db *DB) CreateTransfer(t *models.Transfer) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(
+		`INSERT INTO transfers (ledger_id, user_id, date, description, out_record_id, in_record_id) VALUES (?, ?, ?, ?, 0, 0)`,
+		t.LedgerID, t.UserID, t.Date, t.Description,
+	)
+	if err != nil {
+		return err
+	}
+	t.ID, _ = res.LastInsertId()
+	out, in := transferLegs(t)
+	if err := insertRecord(tx, out); err != nil {
+		return err
+	}
+	if err := insertRecord(tx, in); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE transfers SET out_record_id=?, in_record_id=? WHERE id=?`, out.ID, in.ID, t.ID); err != nil {
+		return err
+	}
+	for _, leg := range []*models.Record{out, in} {
+		if err := writeCreateRevision(tx, leg); err != nil {
+			return err
+		}
+	}
+	t.OutRecordID, t.InRecordID = out.ID, in.ID
+	return tx.Commit()
+}
+
+// transferLegs 由转账生成转出、转入两条分录，各自使用所属账户的币种
+func transferLegs(t *models.Transfer) (out, in *models.Record) {
+	from, to := t.FromAccountID, t.ToAccountID
+	out = &models.Record{
+		LedgerID: t.LedgerID, UserID: t.UserID, Date: t.Date, Amount: -t.Amount, Currency: t.FromCurrency,
+		Category: models.TransferCategory, Description: t.Description, AccountID: &from, TransferID: &t.ID,
+	}
+	in = &models.Record{
+		LedgerID: t.LedgerID, UserID: t.UserID, Date: t.Date, Amount: t.ToAmount, Currency: t.ToCurrency,
+		Category: models.TransferCategory, Description: t.Description, AccountID: &to, TransferID: &t.ID,
+	}
+	return out, in
+}
+
+const transferSelect = `
+	SELECT t.id, t.ledger_id, t.user_id, t.date, COALESCE(t.description,''), t.out_record_id, t.in_record_id,
+		t.version, t.created_at, t.updated_at, -o.amount, i.amount, COALESCE(o.account_id, 0), COALESCE(i.account_id, 0),
+		COALESCE(o.currency,'CNY'), COALESCE(i.currency,'CNY')
+	FROM transfers t
+	JOIN records o ON o.id = t.out_record_id
+	JOIN records i ON i.id = t.in_record_id`
+
+func scanTransfer(s rowScanner) (*models.Transfer, error) {
+	var t models.Transfer
+	if err := s.Scan(&t.ID, &t.LedgerID, &t.UserID, &t.Date, &t.Description, &t.OutRecordID, &t.InRecordID,
+		&t.Version, &t.CreatedAt, &t.UpdatedAt, &t.Amount, &t.ToAmount, &t.FromAccountID, &t.ToAccountID,
+		&t.FromCurrency, &t.ToCurrency); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTransfer 获取账本内的转账，不存在时返回 nil
+func (db *DB) GetTransfer(ledgerID, id int64) (*models.Transfer, error) {
+	return getTransfer(db.conn, ledgerID, id)
+}
+
+func getTransfer(q querier, ledgerID, id int64) (*models.Transfer, error) {
+	t, err := scanTransfer(q.QueryRow(transferSelect+` WHERE t.id = ? AND t.ledger_id = ? AND t.deleted_at IS NULL`, id, ledgerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListTransfers 日期范围内的转账
+func (db *DB) ListTransfers(ledgerID int64, startDate, endDate string) ([]*models.Transfer, error) {
+	where := ` WHERE t.ledger_id = ? AND t.deleted_at IS NULL`
+	args := []interface{}{ledgerID}
+	if startDate != "" {
+		where += ` AND t.date >= ?`
+		args = append(args, startDate)
+	}
+	if endDate != "" {
+		where += ` AND t.date <= ?`
+		args = append(args, endDate)
+	}
+	rows, err := db.conn.Query(transferSelect+where+` ORDER BY t.date DESC, t.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*models.Transfer
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// UpdateTransfer 以 userID 为操作者在同一事务中按合并后的 t 更新转账及两条分录。
+// version 非 0 时须与转账当前版本一致，否则返回 ErrVersionConflict
+func (db *DB) UpdateTransfer(ledgerID, userID, version int64, t *models.Transfer) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	cur, err := getTransfer(tx, ledgerID, t.ID)
+	if err != nil {
+		return err
+	}
+	if cur == nil {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != cur.Version {
+		return ErrVersionConflict
+	}
+	t.OutRecordID, t.InRecordID = cur.OutRecordID, cur.InRecordID
+	res, err := tx.Exec(
+		`UPDATE transfers SET date=?, description=?, version=version+1, updated_at=CURRENT_TIMESTAMP WHERE id=? AND version=?`,
+		t.Date, t.Description, t.ID, cur.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVersionConflict
+	}
+	out, in := transferLegs(t)
+	meta := revisionMeta{UserID: userID, Action: models.RevisionUpdate}
+	if err := trackRevisions(tx, ledgerID, []int64{t.OutRecordID, t.InRecordID}, meta, func() error {
+		for _, leg := range []struct {
+			id int64
+			r  *models.Record
+		}{{t.OutRecordID, out}, {t.InRecordID, in}} {
+			if _, err := tx.Exec(
+				`UPDATE records SET date=?, amount=?, currency=?, description=?, account_id=?, version=version+1, updated_at=CURRENT_TIMESTAMP WHERE id=?`,
+				leg.r.Date, leg.r.Amount, leg.r.Currency, leg.r.Description, nullID(leg.r.AccountID), leg.id,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTransfer 以 userID 为操作者在同一事务中将转账及两条分录移入回收站。
+// version 非 0 时须与转账当前版本一致，否则返回 ErrVersionConflict
+func (db *DB) DeleteTransfer(ledgerID, id, userID, version int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteTransfer(tx, ledgerID, id, userID, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteTransfer 软删除转账及两条分录；version 为 0 时不校验转账版本
+func deleteTransfer(q querier, ledgerID, id, userID, version int64) error {
+	var outID, inID, cur int64
+	err := q.QueryRow(`SELECT out_record_id, in_record_id, version FROM transfers WHERE id=? AND ledger_id=? AND deleted_at IS NULL`, id, ledgerID).Scan(&outID, &inID, &cur)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != cur {
+		return ErrVersionConflict
+	}
+	return trackRevisions(q, ledgerID, []int64{outID, inID}, revisionMeta{UserID: userID, Action: models.RevisionDelete}, func() error {
+		now := deletedNow()
+		if _, err := q.Exec(`UPDATE transfers SET deleted_at=?, version=version+1 WHERE id=?`, now, id); err != nil {
+			return err
+		}
+		_, err := q.Exec(`UPDATE records SET deleted_at=?, version=version+1 WHERE transfer_id=? AND ledger_id=?`, now, id, ledgerID)
+		return err
+	})
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"database/sql"
+	"time"
+)
+
+// migrateTrash 为记录与转账增加软删除时间，删除后进入回收站
+func (db *DB) migrateTrash() error {
+	_, _ = db.conn.Exec(`ALTER TABLE records ADD COLUMN deleted_at DATETIME`)
+	_, _ = db.conn.Exec(`ALTER TABLE transfers ADD COLUMN deleted_at DATETIME`)
+	_, err := db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_records_deleted ON records(ledger_id, deleted_at) WHERE deleted_at IS NOT NULL`)
+	return err
+}
+
+// ListTrash 回收站中的记录，按删除时间倒序分页
+func (db *DB) ListTrash(ledgerID int64, page, pageSize int) ([]*models.Record, int64, error) {
+	var total int64
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM records WHERE ledger_id = ? AND deleted_at IS NOT NULL`, ledgerID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.conn.Query(`SELECT `+recordColumns+`, deleted_at FROM records
+		WHERE ledger_id = ? AND deleted_at IS NOT NULL
+		ORDER BY datetime(deleted_at) DESC, id DESC LIMIT ? OFFSET ?`,
+		ledgerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var list []*models.Record
+	for rows.Next() {
+		var deletedAt time.Time
+		r, err := scanRecord(extraScanner{rows, []interface{}{&deletedAt}})
+		if err != nil {
+			return nil, 0, err
+		}
+		r.DeletedAt = &deletedAt
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := db.attachTags(list); err != nil {
+		return nil, 0, err
+	}
+	if err := db.attachSplits(list); err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// trashedTransferID 返回回收站中记录所属的转账 ID（非转账分录为 0），记录不在回收站时返回 sql.ErrNoRows
+func trashedTransferID(q querier, ledgerID, id int64) (int64, error) {
+	var transferID sql.NullInt64
+	err := q.QueryRow(`SELECT transfer_id FROM records WHERE id = ? AND ledger_id = ? AND deleted_at IS NOT NULL`, id, ledgerID).Scan(&transferID)
+	return transferID.Int64, err
+}
+
+// RestoreRecord 以 userID 为操作者从回收站恢复记录并写入 restore 修订；转账分录连同整笔转账一起恢复
+func (db *DB) RestoreRecord(ledgerID, id, userID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	transferID, err := trashedTransferID(tx, ledgerID, id)
+	if err != nil {
+		return err
+	}
+	ids := []int64{id}
+	if transferID != 0 {
+		var outID, inID int64
+		if err := tx.QueryRow(`SELECT out_record_id, in_record_id FROM transfers WHERE id = ?`, transferID).Scan(&outID, &inID); err != nil {
+			return err
+		}
+		ids = []int64{outID, inID}
+	}
+	if err := trackRevisions(tx, ledgerID, ids, revisionMeta{UserID: userID, Action: models.RevisionRestore}, func() error {
+		if transferID != 0 {
+			if _, err := tx.Exec(`UPDATE transfers SET deleted_at = NULL WHERE id = ? AND ledger_id = ?`, transferID, ledgerID); err != nil {
+				return err
+			}
+		}
+		args := []interface{}{ledgerID}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		_, err := tx.Exec(`UPDATE records SET deleted_at = NULL, version = version + 1 WHERE ledger_id = ? AND id IN (`+placeholders(len(ids))+`)`, args...)
+		return err
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeRecord 彻底删除回收站中的记录及其标签关联、拆分行、修订历史与附件信息，返回须从存储后端删除的附件 key；
+// 转账分录连同整笔转账一起删除
+func (db *DB) PurgeRecord(ledgerID, id int64) ([]string, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	transferID, err := trashedTransferID(tx, ledgerID, id)
+	if err != nil {
+		return nil, err
+	}
+	cond, args := `id = ?`, []interface{}{id}
+	if transferID != 0 {
+		if _, err := tx.Exec(`DELETE FROM transfers WHERE id = ? AND ledger_id = ?`, transferID, ledgerID); err != nil {
+			return nil, err
+		}
+		cond, args = `transfer_id = ?`, []interface{}{transferID}
+	}
+	keys, err := purgeRecords(tx, `ledger_id = ? AND `+cond, append([]interface{}{ledgerID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return keys, tx.Commit()
+}
+
+// EmptyTrash 清空账本的回收站，返回彻底删除的记录数与须从存储后端删除的附件 key
+func (db *DB) EmptyTrash(ledgerID int64) (int64, []string, error) {
+	return db.purgeTrash(`ledger_id = ? AND deleted_at IS NOT NULL`, ledgerID)
+}
+
+// PurgeTrashBefore 彻底删除所有账本中在 before 之前移入回收站的记录与转账，返回值同 EmptyTrash
+func (db *DB) PurgeTrashBefore(before time.Time) (int64, []string, error) {
+	return db.purgeTrash(`deleted_at IS NOT NULL AND datetime(deleted_at) < ?`, dbTime(before))
+}
+
+// purgeTrash 彻底删除满足 cond 的回收站记录与转账。转账两条分录的删除时间相同，会同时命中
+func (db *DB) purgeTrash(cond string, args ...interface{}) (int64, []string, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+	var n int64
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM records WHERE `+cond, args...).Scan(&n); err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM transfers WHERE `+cond, args...); err != nil {
+		return 0, nil, err
+	}
+	keys, err := purgeRecords(tx, cond, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, keys, tx.Commit()
+}
+
+// purgeRecords 物理删除满足 cond 的记录及其标签关联、拆分行、修订历史与附件信息，返回附件的存储 key
+func purgeRecords(q querier, cond string, args ...interface{}) ([]string, error) {
+	keys, err := purgeAttachments(q, cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range []string{"record_tags", "record_splits", "record_revisions"} {
+		if _, err := q.Exec(`DELETE FROM `+table+` WHERE record_id IN (SELECT id FROM records WHERE `+cond+`)`, args...); err != nil {
+			return nil, err
+		}
+	}
+	_, err = q.Exec(`DELETE FROM records WHERE `+cond, args...)
+	return keys, err
+}
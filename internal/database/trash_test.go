@@ -0,0 +1,103 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"testing"
+)
+
+// newTestTransfer 在 1 号账本中新建两个账户及其间的一笔转账
+func newTestTransfer(t *testing.T, db *DB) *models.Transfer {
+	t.Helper()
+	var ids []int64
+	for _, name := range []string{"cash", "bank"} {
+		a := &models.Account{LedgerID: 1, Name: name, Currency: "CNY"}
+		if err := db.CreateAccount(a); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.ID)
+	}
+	tr := &models.Transfer{LedgerID: 1, UserID: 1, Date: "2024-03-01", Amount: 1000, ToAmount: 1000,
+		FromAccountID: ids[0], ToAccountID: ids[1], FromCurrency: "CNY", ToCurrency: "CNY"}
+	if err := db.CreateTransfer(tr); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func countRows(t *testing.T, db *DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.conn.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestRestoreTransfer 从回收站恢复任一条分录时整笔转账及两条分录一起恢复
+func TestRestoreTransfer(t *testing.T) {
+	db := newTestDB(t)
+	tr := newTestTransfer(t, db)
+	if err := db.DeleteTransfer(1, tr.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := db.ListTrash(1, 1, 20); err != nil || total != 2 {
+		t.Fatalf("trash after delete = %d, %v; want both legs", total, err)
+	}
+	if err := db.RestoreRecord(1, tr.InRecordID, 1); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetTransfer(1, tr.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetTransfer after restore = %v, %v", got, err)
+	}
+	if got.Amount != tr.Amount || got.FromAccountID != tr.FromAccountID || got.ToAccountID != tr.ToAccountID {
+		t.Errorf("restored transfer = %+v", got)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM records WHERE transfer_id = ? AND deleted_at IS NULL`, tr.ID); n != 2 {
+		t.Errorf("restored legs = %d, want 2", n)
+	}
+	for _, id := range []int64{tr.OutRecordID, tr.InRecordID} {
+		revs, err := db.ListRevisions(1, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revs) == 0 || revs[0].Action != models.RevisionRestore {
+			t.Errorf("record %d lacks a restore revision", id)
+		}
+	}
+}
+
+// TestPurgeTransfer 彻底删除任一条分录时整笔转账及两条分录与其修订一并删除，不影响其他回收站记录
+func TestPurgeTransfer(t *testing.T) {
+	db := newTestDB(t)
+	tr := newTestTransfer(t, db)
+	other := &models.Record{LedgerID: 1, UserID: 1, Date: "2024-03-02", Amount: -100, Currency: "CNY"}
+	if err := db.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(1, other.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteTransfer(1, tr.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PurgeRecord(1, tr.OutRecordID); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM transfers WHERE id = ?`, tr.ID); n != 0 {
+		t.Errorf("transfer rows = %d, want 0", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM records WHERE id IN (?, ?)`, tr.OutRecordID, tr.InRecordID); n != 0 {
+		t.Errorf("leg rows = %d, want 0", n)
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM record_revisions WHERE record_id IN (?, ?)`, tr.OutRecordID, tr.InRecordID); n != 0 {
+		t.Errorf("leg revisions = %d, want 0", n)
+	}
+	list, total, err := db.ListTrash(1, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || list[0].ID != other.ID {
+		t.Errorf("trash after purge = %d records, want only %d", total, other.ID)
+	}
+}
@@ -20,6 +20,10 @@ func (db *DB) migrateUsers() error {
 	}
 	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'user'`)
 	_, _ = db.conn.Exec(`UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users)`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN base_currency TEXT DEFAULT 'CNY'`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN week_start TEXT DEFAULT 'iso'`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN month_start_day INTEGER DEFAULT 1`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN year_start_month INTEGER DEFAULT 1`)
 	if err := db.migrateLoginLogs(); err != nil {
 		return err
 	}
@@ -120,14 +124,69 @@ func (db *DB) UpdateUser(id int64, username, role string) error {
 	return nil
 }
 
-func (db *DB) DeleteUser(id int64) error {
-	res, err := db.conn.Exec(`DELETE FROM users WHERE id=?`, id)
+// DeleteUser 删除用户及其账本成员关系。用户是某个共享账本的唯一所有者时返回 ErrLastOwner，
+// 需先转移所有权；仅有其本人的账本连同其中的全部数据在同一事务中一并删除，返回须从存储后端删除的附件 key
+func (db *DB) DeleteUser(id int64) ([]string, error) {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback()
+	var shared int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM ledger_members m
+		WHERE m.user_id = ? AND m.role = ?
+		  AND NOT EXISTS (SELECT 1 FROM ledger_members o WHERE o.ledger_id = m.ledger_id AND o.role = ? AND o.user_id <> m.user_id)
+		  AND EXISTS (SELECT 1 FROM ledger_members o WHERE o.ledger_id = m.ledger_id AND o.user_id <> m.user_id)
+	`, id, models.LedgerRoleOwner, models.LedgerRoleOwner).Scan(&shared)
+	if err != nil {
+		return nil, err
+	}
+	if shared > 0 {
+		return nil, ErrLastOwner
+	}
+	res, err := tx.Exec(`DELETE FROM users WHERE id=?`, id)
+	if err != nil {
+		return nil, err
 	}
 	n, _ := res.RowsAffected()
 	if n == 0 {
-		return sql.ErrNoRows
+		return nil, sql.ErrNoRows
 	}
-	return nil
+	ledgers, err := soleMemberLedgers(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, ledgerID := range ledgers {
+		k, err := purgeLedger(tx, ledgerID)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k...)
+	}
+	if _, err := tx.Exec(`DELETE FROM ledger_members WHERE user_id=?`, id); err != nil {
+		return nil, err
+	}
+	return keys, tx.Commit()
+}
+
+// GetUserSettings 用户偏好设置，用户不存在时返回默认值
+func (db *DB) GetUserSettings(userID int64) (*models.UserSettings, error) {
+	s := &models.UserSettings{BaseCurrency: models.DefaultCurrency, WeekStart: models.WeekStartISO, MonthStartDay: 1, YearStartMonth: 1}
+	err := db.conn.QueryRow(
+		`SELECT COALESCE(base_currency, ?), COALESCE(week_start, ?), COALESCE(month_start_day, 1), COALESCE(year_start_month, 1)
+		FROM users WHERE id = ?`, models.DefaultCurrency, models.WeekStartISO, userID,
+	).Scan(&s.BaseCurrency, &s.WeekStart, &s.MonthStartDay, &s.YearStartMonth)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateUserSettings 保存用户偏好设置
+func (db *DB) UpdateUserSettings(userID int64, s *models.UserSettings) error {
+	_, err := db.conn.Exec(`UPDATE users SET base_currency = ?, week_start = ?, month_start_day = ?, year_start_month = ? WHERE id = ?`,
+		s.BaseCurrency, s.WeekStart, s.MonthStartDay, s.YearStartMonth, userID)
+	return err
 }
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"account-service/internal/models"
+	"reflect"
+	"testing"
+)
+
+// TestDeleteUserPurgesSoleLedgers 删除用户时仅有其本人的账本连同数据一并删除，共享账本保留
+func TestDeleteUserPurgesSoleLedgers(t *testing.T) {
+	db := newTestDB(t)
+	alice := &models.User{Username: "alice"}
+	bob := &models.User{Username: "bob"}
+	for _, u := range []*models.User{alice, bob} {
+		if err := db.CreateUser(u, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	personal := &models.Ledger{Name: "personal"}
+	shared := &models.Ledger{Name: "shared"}
+	for _, l := range []*models.Ledger{personal, shared} {
+		if err := db.CreateLedger(l, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.AddLedgerMember(shared.ID, bob.ID, models.LedgerRoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*models.Ledger{personal, shared} {
+		a := &models.Account{LedgerID: l.ID, Name: "cash", Currency: "CNY"}
+		if err := db.CreateAccount(a); err != nil {
+			t.Fatal(err)
+		}
+		r := &models.Record{LedgerID: l.ID, UserID: alice.ID, Date: "2024-03-01", Amount: -100, Currency: "CNY", Description: "lunch"}
+		if err := db.Create(r); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CreateAttachment(&models.Attachment{LedgerID: l.ID, RecordID: r.ID, UserID: alice.ID, Filename: "a.jpg",
+			StorageKey: l.Name + "/a", ThumbKey: l.Name + "/a_thumb.jpg"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Delete(l.ID, r.ID, alice.ID, 0); err != nil { // 回收站中的记录同样删除
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := db.DeleteUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"personal/a", "personal/a_thumb.jpg"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("DeleteUser keys = %q, want %q", keys, want)
+	}
+	count := func(query string, args ...interface{}) int {
+		var n int
+		if err := db.conn.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	for _, table := range []string{"records", "accounts", "attachments", "record_revisions", "ledger_members"} {
+		if n := count(`SELECT COUNT(*) FROM `+table+` WHERE ledger_id = ?`, personal.ID); n != 0 {
+			t.Errorf("%s left in personal ledger: %d", table, n)
+		}
+		if n := count(`SELECT COUNT(*) FROM `+table+` WHERE ledger_id = ?`, shared.ID); n == 0 {
+			t.Errorf("%s purged from shared ledger", table)
+		}
+	}
+	if n := count(`SELECT COUNT(*) FROM ledgers WHERE id = ?`, personal.ID); n != 0 {
+		t.Error("personal ledger not deleted")
+	}
+	if n := count(`SELECT COUNT(*) FROM ledger_members WHERE user_id = ?`, alice.ID); n != 0 {
+		t.Errorf("memberships left: %d", n)
+	}
+}
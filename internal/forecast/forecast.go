@@ -0,0 +1,284 @@
+// Package forecast 按历史日常收支与周期记账规则预测本月与本财年末的收支、结余及置信区间
+package forecast
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/models"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// bandLevels 返回的置信水平及对应的标准正态分位数
+var bandLevels = []struct{ level, z float64 }{{0.8, 1.2816}, {0.95, 1.96}}
+
+type Forecaster struct {
+	db *database.DB
+}
+
+func New(db *database.DB) *Forecaster {
+	return &Forecaster{db: db}
+}
+
+// Project 以 date（含当日）为止的实际数据预测 date 所在月与财年（按 cal 划分）末的收支。
+// 日常收支模型用 date 之前 history 天（不早于账本首条日常记录）的数据拟合，不含周期记账生成的记录，
+// 周期记账规则按计划逐期计入，避免重复。日常支出按逐日模型预测，日常收入多为不定期的大额到账，
+// 按周期总额预测（见 Lumpy）
+func (f *Forecaster) Project(ledgerID int64, date time.Time, cal models.Calendar, base string, history int) (*models.Forecast, error) {
+	histStart := date.AddDate(0, 0, 1-history)
+	days, err := f.db.DailyTotals(ledgerID, histStart.Format("2006-01-02"), date.Format("2006-01-02"), base, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	expense := make([]float64, history)
+	income := make([]float64, history)
+	first := history
+	for _, d := range days {
+		t, err := time.Parse("2006-01-02", d.Period)
+		if err != nil {
+			return nil, err
+		}
+		i := int(t.Sub(histStart).Hours() / 24)
+		expense[i], income[i] = float64(d.Expense), float64(d.Income)
+		if i < first {
+			first = i
+		}
+	}
+	fitStart := histStart.AddDate(0, 0, first)
+	em := Fit(fitStart, expense[first:])
+	im := FitLumpy(income[first:])
+
+	fc := &models.Forecast{
+		Date:     date.Format("2006-01-02"),
+		Currency: base,
+		Model: &models.ForecastModel{
+			HistoryDays: em.Days,
+			Window:      em.Window,
+			Level:       models.Money(math.Round(em.Level)),
+			ResidualSD:  models.Money(math.Round(em.SD)),
+			Income:      models.IncomeStats{Level: models.Money(math.Round(im.Rate)), Lump: models.Money(math.Round(im.Lump))},
+		},
+		Warnings: []*models.ForecastAlert{},
+	}
+	for wd, v := range em.Weekday {
+		fc.Model.Weekday[wd] = models.Money(math.Round(v))
+	}
+	rules, err := f.db.ListRecurringRules(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	ms := cal.MonthStart(date)
+	if fc.Month, err = f.period(ledgerID, base, ms.Format("2006-01"), ms, ms.AddDate(0, 1, -1), date, em, im, rules); err != nil {
+		return nil, err
+	}
+	ys := cal.YearStart(date)
+	if fc.Year, err = f.period(ledgerID, base, fmt.Sprintf("%04d", ys.Year()), ys, ys.AddDate(1, 0, -1), date, em, im, rules); err != nil {
+		return nil, err
+	}
+	if err := f.budgetAlerts(fc, ledgerID, base, date, fitStart, cal, em, rules); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// period 预测 [start, end] 周期末的收支：截至 date 的实际值 + 之后的周期记账 + 模型预测的日常收支
+func (f *Forecaster) period(ledgerID int64, base, label string, start, end, date time.Time,
+	em *Model, im *Lumpy, rules []*models.RecurringRule) (*models.PeriodForecast, error) {
+	p := &models.PeriodForecast{
+		Period:        label,
+		StartDate:     start.Format("2006-01-02"),
+		EndDate:       end.Format("2006-01-02"),
+		DaysElapsed:   days(start, date) + 1,
+		DaysRemaining: days(date, end),
+		Recurring:     []*models.RecurringItem{},
+	}
+	actual, err := f.db.Summary(ledgerID, models.SummaryQuery{StartDate: p.StartDate, EndDate: date.Format("2006-01-02")}, base)
+	if err != nil {
+		return nil, err
+	}
+	p.ActualIncome, p.ActualExpense = actual.Income, actual.Expense
+	// 本周期已到账的日常收入（不含周期记账），从周期预计总额中扣除
+	daily, err := f.db.DailyTotals(ledgerID, p.StartDate, date.Format("2006-01-02"), base, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	var received float64
+	for _, d := range daily {
+		received += float64(d.Income)
+	}
+
+	// 规则在 date 之后到周期结束的各期。与已生成到哪一期（Seq）无关：date 在过去时，
+	// 其后已生成的记录不在实际值内，仍需按计划计入
+	after := date.Format("2006-01-02")
+	for _, r := range rules {
+		if !r.Active {
+			continue
+		}
+		for n := 0; ; n++ {
+			d := r.Occurrence(n)
+			if d == "" || d > p.EndDate || (r.EndDate != "" && d > r.EndDate) {
+				break
+			}
+			if d <= after {
+				continue
+			}
+			amount, ok, err := f.db.ConvertAmount(r.Amount, r.Currency, base, d)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			p.Recurring = append(p.Recurring, &models.RecurringItem{RuleID: r.ID, Name: r.Name, Date: d, Amount: amount})
+			if amount > 0 {
+				p.RecurringIncome += amount
+			} else {
+				p.RecurringExpense -= amount
+			}
+		}
+	}
+
+	sort.SliceStable(p.Recurring, func(i, j int) bool { return p.Recurring[i].Date < p.Recurring[j].Date })
+
+	next := date.AddDate(0, 0, 1)
+	expMean, expVar := em.Project(next, p.DaysRemaining)
+	incMean, incVar := im.Project(days(start, end)+1, p.DaysRemaining, received)
+	p.Expense = value(float64(p.ActualExpense+p.RecurringExpense), expMean, expVar, true)
+	p.Income = value(float64(p.ActualIncome+p.RecurringIncome), incMean, incVar, true)
+	known := float64(p.ActualIncome + p.RecurringIncome - p.ActualExpense - p.RecurringExpense)
+	p.Balance = value(known, incMean-expMean, incVar+expVar, false)
+	return p, nil
+}
+
+// budgetAlerts 预算（与本位币相同币种）的本期预计支出超出可用额度时给出提醒。
+// 总预算取周期预测的支出；分类预算单独拟合该分类及其子分类的日常支出，并计入该分类的周期记账
+func (f *Forecaster) budgetAlerts(fc *models.Forecast, ledgerID int64, base string, date, fitStart time.Time,
+	cal models.Calendar, em *Model, rules []*models.RecurringRule) error {
+	list, err := f.db.BudgetStatus(ledgerID, date.Format("2006-01-02"), 1, cal)
+	if err != nil {
+		return err
+	}
+	var parents map[int64]int64
+	for _, st := range list {
+		b := st.Budget
+		if b.Currency != fc.Currency || st.Current == nil {
+			continue
+		}
+		period, p, name := "month", fc.Month, "本月"
+		if b.Period == models.BudgetPeriodYearly {
+			period, p, name = "year", fc.Year, "本财年"
+		}
+		projected := p.Expense.Projected
+		_, variance := em.Project(date.AddDate(0, 0, 1), p.DaysRemaining)
+		if b.CategoryID != nil {
+			if parents == nil {
+				if parents, err = f.categoryParents(ledgerID); err != nil {
+					return err
+				}
+			}
+			if projected, variance, err = f.categoryExpense(ledgerID, base, *b.CategoryID, p, date, fitStart, rules, parents); err != nil {
+				return err
+			}
+			name += "「" + b.Category + "」"
+		}
+		if projected <= st.Current.Available {
+			continue
+		}
+		prob := 1.0
+		if variance > 0 {
+			prob = 1 - normalCDF(float64(st.Current.Available-projected)/math.Sqrt(variance))
+		}
+		over := projected - st.Current.Available
+		fc.Warnings = append(fc.Warnings, &models.ForecastAlert{
+			Period:      period,
+			BudgetID:    b.ID,
+			CategoryID:  b.CategoryID,
+			Available:   st.Current.Available,
+			Projected:   projected,
+			OverBy:      over,
+			Probability: math.Round(prob*100) / 100,
+			Message:     fmt.Sprintf("按当前趋势%s支出预计为 %s，将超出预算 %s", name, projected.String(), over.String()),
+		})
+	}
+	return nil
+}
+
+// categoryExpense 分类（含子分类）在周期 p 的预计支出及方差：截至 date 的实际支出 + 之后该分类的周期记账 +
+// 按该分类自 fitStart 起的日常支出拟合的预测
+func (f *Forecaster) categoryExpense(ledgerID int64, base string, categoryID int64, p *models.PeriodForecast,
+	date, fitStart time.Time, rules []*models.RecurringRule, parents map[int64]int64) (models.Money, float64, error) {
+	actual, err := f.db.DailyTotals(ledgerID, p.StartDate, date.Format("2006-01-02"), base, false, categoryID)
+	if err != nil {
+		return 0, 0, err
+	}
+	var known models.Money
+	for _, d := range actual {
+		known += d.Expense
+	}
+	ruleCategory := map[int64]int64{}
+	for _, r := range rules {
+		if r.CategoryID != nil {
+			ruleCategory[r.ID] = *r.CategoryID
+		}
+	}
+	for _, it := range p.Recurring {
+		c, ok := ruleCategory[it.RuleID]
+		if ok && (c == categoryID || parents[c] == categoryID) && it.Amount < 0 {
+			known -= it.Amount
+		}
+	}
+
+	history, err := f.db.DailyTotals(ledgerID, fitStart.Format("2006-01-02"), date.Format("2006-01-02"), base, true, categoryID)
+	if err != nil {
+		return 0, 0, err
+	}
+	values := make([]float64, days(fitStart, date)+1)
+	for _, d := range history {
+		t, err := time.Parse("2006-01-02", d.Period)
+		if err != nil {
+			return 0, 0, err
+		}
+		values[days(fitStart, t)] = float64(d.Expense)
+	}
+	mean, variance := Fit(fitStart, values).Project(date.AddDate(0, 0, 1), p.DaysRemaining)
+	return models.Money(math.Round(float64(known) + mean)), variance, nil
+}
+
+// categoryParents 子分类到一级分类的映射
+func (f *Forecaster) categoryParents(ledgerID int64) (map[int64]int64, error) {
+	tree, err := f.db.ListCategories(ledgerID, "")
+	if err != nil {
+		return nil, err
+	}
+	parents := map[int64]int64{}
+	for _, c := range tree {
+		for _, child := range c.Children {
+			parents[child.ID] = c.ID
+		}
+	}
+	return parents, nil
+}
+
+// value 已知部分加模型预测部分；nonNegative 时模型部分的区间下限不低于 0
+func value(known, mean, variance float64, nonNegative bool) models.ForecastValue {
+	v := models.ForecastValue{Projected: models.Money(math.Round(known + mean))}
+	sd := math.Sqrt(variance)
+	for _, b := range bandLevels {
+		low := mean - b.z*sd
+		if nonNegative && low < 0 {
+			low = 0
+		}
+		v.Bands = append(v.Bands, models.ConfidenceBand{
+			Level: b.level,
+			Low:   models.Money(math.Round(known + low)),
+			High:  models.Money(math.Round(known + mean + b.z*sd)),
+		})
+	}
+	return v
+}
+
+// days 从 a 到 b 的天数
+func days(a, b time.Time) int {
+	return int(math.Round(b.Sub(a).Hours() / 24))
+}
@@ -0,0 +1,139 @@
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// movingWindow 估计近期水平的移动平均窗口（四周，使各星期的权重相同）
+	movingWindow = 28
+	// seasonalMinDays 估计星期季节项所需的最少天数
+	seasonalMinDays = 14
+)
+
+// Model 按日金额序列的加法分解：近期移动平均水平 + 星期季节项 + 残差。
+// 预测某天的值为 Level + Weekday[星期]（不小于 0），残差视为独立同分布
+type Model struct {
+	Days    int        // 拟合天数
+	Window  int        // 移动平均实际使用的天数
+	Level   float64    // 去季节后的近期日均值
+	Weekday [7]float64 // 按 time.Weekday 索引的季节项，合计为 0
+	SD      float64    // 近期去季节值相对 Level 的标准差
+}
+
+// Fit 拟合从 start 起的按日序列
+func Fit(start time.Time, values []float64) *Model {
+	n := len(values)
+	m := &Model{Days: n}
+	if n == 0 {
+		return m
+	}
+	weekday := func(t int) int { return int(start.AddDate(0, 0, t).Weekday()) }
+
+	// 季节项：以 7 日中心移动平均为趋势，各星期去趋势值的平均
+	if n >= seasonalMinDays {
+		var sum [7]float64
+		var cnt [7]int
+		for t := 3; t < n-3; t++ {
+			wd := weekday(t)
+			sum[wd] += values[t] - mean(values[t-3:t+4])
+			cnt[wd]++
+		}
+		var total float64
+		for wd := range sum {
+			if cnt[wd] > 0 {
+				m.Weekday[wd] = sum[wd] / float64(cnt[wd])
+			}
+			total += m.Weekday[wd]
+		}
+		for wd := range m.Weekday {
+			m.Weekday[wd] -= total / 7
+		}
+	}
+
+	// 水平与离散程度：最近 Window 天的去季节值
+	m.Window = movingWindow
+	if n < m.Window {
+		m.Window = n
+	}
+	recent := make([]float64, 0, m.Window)
+	for t := n - m.Window; t < n; t++ {
+		recent = append(recent, values[t]-m.Weekday[weekday(t)])
+	}
+	m.Level = mean(recent)
+	if len(recent) > 1 {
+		var ss float64
+		for _, v := range recent {
+			ss += (v - m.Level) * (v - m.Level)
+		}
+		m.SD = math.Sqrt(ss / float64(len(recent)-1))
+	}
+	return m
+}
+
+// Project 从 from 起 days 天的合计预测值及方差。
+// 方差含逐日残差（days·σ²）与水平估计误差（(days·σ)²/Window）两部分
+func (m *Model) Project(from time.Time, days int) (total, variance float64) {
+	if days <= 0 || m.Days == 0 {
+		return 0, 0
+	}
+	for i := 0; i < days; i++ {
+		total += math.Max(0, m.Level+m.Weekday[from.AddDate(0, 0, i).Weekday()])
+	}
+	h := float64(days)
+	variance = h*m.SD*m.SD + h*h*m.SD*m.SD/float64(m.Window)
+	return total, variance
+}
+
+// Lumpy 不定期到账的收入（工资、奖金、报销等）按复合泊松过程建模：
+// 一个周期内的期望总额为 Rate·周期天数，方差为期望额·Lump。
+// 日均移动平均会把已到账的一笔大额收入摊到剩余每一天，因此不用于收入
+type Lumpy struct {
+	Days int     // 拟合天数
+	Rate float64 // 日均金额
+	Lump float64 // 按金额加权的平均单笔规模 Σx²/Σx
+}
+
+// FitLumpy 拟合按日金额序列
+func FitLumpy(values []float64) *Lumpy {
+	l := &Lumpy{Days: len(values)}
+	var sum, sq float64
+	for _, v := range values {
+		if v > 0 {
+			sum += v
+			sq += v * v
+		}
+	}
+	if sum > 0 {
+		l.Rate = sum / float64(len(values))
+		l.Lump = sq / sum
+	}
+	return l
+}
+
+// Project 长度为 periodDays 天、已到账 received、剩余 remaining 天的周期内，剩余天数的预计金额及方差：
+// 周期期望总额扣除已到账部分（不小于 0）
+func (l *Lumpy) Project(periodDays, remaining int, received float64) (total, variance float64) {
+	if remaining <= 0 || l.Days == 0 {
+		return 0, 0
+	}
+	total = math.Max(0, l.Rate*float64(periodDays)-received)
+	return total, total * l.Lump
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// normalCDF 标准正态分布函数
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	db *database.DB
+}
+
+func NewAccountHandler(db *database.DB) *AccountHandler {
+	return &AccountHandler{db: db}
+}
+
+// ListAccounts 账户列表 GET /api/accounts?include_archived=true
+func (h *AccountHandler) ListAccounts(c *gin.Context) {
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+	list, err := h.db.ListAccounts(middleware.GetLedgerID(c), includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetAccount 获取单个账户
+func (h *AccountHandler) GetAccount(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	a, err := h.db.GetAccount(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if a == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账户不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// CreateAccount 创建账户
+func (h *AccountHandler) CreateAccount(c *gin.Context) {
+	var req models.CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type == "" {
+		req.Type = models.AccountTypeOther
+	}
+	if !models.ValidAccountType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 须为 cash、bank、credit、ewallet 或 other"})
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
+	if _, ok := models.NormalizeCurrency(req.Currency); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency 无效"})
+		return
+	}
+	a := &models.Account{
+		LedgerID:       middleware.GetLedgerID(c),
+		Name:           req.Name,
+		Type:           req.Type,
+		OpeningBalance: req.OpeningBalance,
+		Currency:       req.Currency,
+	}
+	if err := h.db.CreateAccount(a); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreateAccount, "account", strconv.FormatInt(a.ID, 10), "创建账户:"+a.Name, c.ClientIP(), c.GetHeader("User-Agent"))
+	a, _ = h.db.GetAccount(a.LedgerID, a.ID)
+	c.JSON(http.StatusCreated, a)
+}
+
+// UpdateAccount 更新账户（含归档/取消归档）
+func (h *AccountHandler) UpdateAccount(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type != nil && !models.ValidAccountType(*req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 须为 cash、bank、credit、ewallet 或 other"})
+		return
+	}
+	if req.Currency != nil {
+		if _, ok := models.NormalizeCurrency(*req.Currency); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency 无效"})
+			return
+		}
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	if err := h.db.UpdateAccount(ledgerID, id, &req); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "账户不存在"})
+		case errors.Is(err, database.ErrAccountCurrencyLocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateAccount, "account", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	a, _ := h.db.GetAccount(ledgerID, id)
+	c.JSON(http.StatusOK, a)
+}
+
+// DeleteAccount 删除账户（仅限无记录的账户，否则应归档）
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.db.DeleteAccount(middleware.GetLedgerID(c), id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "账户不存在"})
+		case errors.Is(err, database.ErrAccountInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteAccount, "account", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// Balances 账户余额 GET /api/accounts/balances?date=2024-06-30&include_archived=true
+// date 缺省为今天；返回截至 date 的余额及当前余额
+func (h *AccountHandler) Balances(c *gin.Context) {
+	today := time.Now().Format("2006-01-02")
+	date := c.DefaultQuery("date", today)
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date 格式应为 YYYY-MM-DD"})
+		return
+	}
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+	list, err := h.db.AccountBalances(middleware.GetLedgerID(c), date, today, includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"date": date, "data": list})
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"account-service/internal/storage"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	thumbnailSize      = 256              // 缩略图长边像素
+	thumbnailMaxPixels = 12 * 1000 * 1000 // 超过该像素数的图片不生成缩略图
+	thumbnailSamples   = 4                // 每个缩略图像素在每个方向上的最多采样点数
+	thumbnailTimeout   = 3 * time.Second  // 上传请求等待缩略图的最长时间
+)
+
+// thumbnailSlots 限制同时进行的缩略图解码数量，避免并发上传大图耗尽内存
+var thumbnailSlots = make(chan struct{}, 2)
+
+type AttachmentHandler struct {
+	db      *database.DB
+	files   storage.Storage
+	maxSize int64 // 单个附件大小上限（字节）
+}
+
+func NewAttachmentHandler(db *database.DB, files storage.Storage, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{db: db, files: files, maxSize: maxSize}
+}
+
+// ListAttachments 记录的附件列表（含回收站中的记录） GET /api/records/:id/attachments
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	ok, err := h.db.RecordExists(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	list, err := h.db.ListAttachments(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// UploadAttachment 上传附件 POST /api/records/:id/attachments（multipart/form-data，文件字段 file）
+// 类型按文件内容识别，仅允许图片与 PDF；图片同时生成 JPEG 缩略图
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	r, err := h.db.GetByID(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if r == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	n, err := h.db.CountAttachments(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if n >= models.AttachmentMaxPerRecord {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每条记录最多 %d 个附件", models.AttachmentMaxPerRecord)})
+		return
+	}
+
+	// 为 multipart 头部预留 1MB，文件本身的大小在读取时再检查
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.tooLarge(c)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少文件字段 file"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, h.maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if int64(len(data)) > h.maxSize {
+		h.tooLarge(c)
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件为空"})
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !models.AttachmentContentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的文件类型: " + contentType})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	a := &models.Attachment{
+		LedgerID:    ledgerID,
+		RecordID:    id,
+		UserID:      middleware.GetUserID(c),
+		Filename:    sanitizeFilename(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		StorageKey:  strconv.FormatInt(ledgerID, 10) + "/" + randomHex(16),
+	}
+	if err := h.files.Put(a.StorageKey, bytes.NewReader(data)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if thumb, err := boundedThumbnail(data); err != nil {
+		log.Printf("生成缩略图 %s: %v", a.StorageKey, err)
+	} else if thumb != nil {
+		key := a.StorageKey + "_thumb.jpg"
+		if err := h.files.Put(key, bytes.NewReader(thumb)); err != nil {
+			log.Printf("保存缩略图 %s: %v", key, err)
+		} else {
+			a.ThumbKey = key
+		}
+	}
+	if err := h.db.CreateAttachment(a); err != nil {
+		deleteFiles(h.files, []string{a.StorageKey, a.ThumbKey})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.HasThumbnail = a.ThumbKey != ""
+
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(a.UserID, username.(string), database.OpUploadAttachment, "record", strconv.FormatInt(id, 10),
+		a.Filename, c.ClientIP(), c.GetHeader("User-Agent"))
+	if created, err := h.db.GetAttachment(ledgerID, id, a.ID); err == nil && created != nil {
+		a = created
+	}
+	c.JSON(http.StatusCreated, a)
+}
+
+func (h *AttachmentHandler) tooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", h.maxSize>>20)})
+}
+
+// DownloadAttachment 下载附件原文件 GET /api/records/:id/attachments/:aid
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	a := h.getAttachment(c)
+	if a == nil {
+		return
+	}
+	h.serve(c, a.StorageKey, a.ContentType, a.Size, a.Filename, a.SHA256)
+}
+
+// DownloadThumbnail 下载图片附件的缩略图（JPEG） GET /api/records/:id/attachments/:aid/thumbnail
+func (h *AttachmentHandler) DownloadThumbnail(c *gin.Context) {
+	a := h.getAttachment(c)
+	if a == nil {
+		return
+	}
+	if a.ThumbKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该附件没有缩略图"})
+		return
+	}
+	name := strings.TrimSuffix(a.Filename, path.Ext(a.Filename)) + "_thumb.jpg"
+	h.serve(c, a.ThumbKey, "image/jpeg", -1, name, a.SHA256+"-thumb")
+}
+
+// serve 从存储后端读取文件并以 inline 方式返回，size 未知时传 -1
+func (h *AttachmentHandler) serve(c *gin.Context, key, contentType string, size int64, filename, etag string) {
+	rc, err := h.files.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "附件文件不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": filename}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+		"ETag":                   `"` + etag + `"`,
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, rc, headers)
+}
+
+// DeleteAttachment 删除附件 DELETE /api/records/:id/attachments/:aid
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	id, aid, ok := attachmentIDs(c)
+	if !ok {
+		return
+	}
+	keys, err := h.db.DeleteAttachment(middleware.GetLedgerID(c), id, aid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deleteFiles(h.files, keys)
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteAttachment, "record", strconv.FormatInt(id, 10),
+		"附件 "+strconv.FormatInt(aid, 10), c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// getAttachment 按路由参数获取附件，失败时已写入错误响应并返回 nil
+func (h *AttachmentHandler) getAttachment(c *gin.Context) *models.Attachment {
+	id, aid, ok := attachmentIDs(c)
+	if !ok {
+		return nil
+	}
+	a, err := h.db.GetAttachment(middleware.GetLedgerID(c), id, aid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if a == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+	}
+	return a
+}
+
+func attachmentIDs(c *gin.Context) (id, aid int64, ok bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	aid, err = strconv.ParseInt(c.Param("aid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+		return 0, 0, false
+	}
+	return id, aid, true
+}
+
+// deleteFiles 删除彻底删除的记录留下的附件文件，失败只记日志（数据库中已无引用）
+func (h *RecordHandler) deleteFiles(keys []string) {
+	deleteFiles(h.files, keys)
+}
+
+func deleteFiles(files storage.Storage, keys []string) {
+	var nonEmpty []string
+	for _, k := range keys {
+		if k != "" {
+			nonEmpty = append(nonEmpty, k)
+		}
+	}
+	if err := storage.DeleteKeys(files, nonEmpty); err != nil {
+		log.Printf("删除附件文件: %v", err)
+	}
+}
+
+// sanitizeFilename 去掉客户端文件名中的路径与控制字符，限制长度
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name))
+	if runes := []rune(name); len(runes) > 200 {
+		name = string(runes[:200])
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	return name
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// boundedThumbnail 在并发数与耗时限制内生成缩略图：已有过多任务在运行时直接跳过，
+// 超时则上传不带缩略图继续完成（后台任务结束后才释放名额）
+func boundedThumbnail(data []byte) ([]byte, error) {
+	select {
+	case thumbnailSlots <- struct{}{}:
+	default:
+		return nil, nil
+	}
+	type result struct {
+		thumb []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-thumbnailSlots }()
+		thumb, err := makeThumbnail(data)
+		done <- result{thumb, err}
+	}()
+	select {
+	case r := <-done:
+		return r.thumb, r.err
+	case <-time.After(thumbnailTimeout):
+		return nil, errors.New("生成缩略图超时")
+	}
+}
+
+// makeThumbnail 为 JPEG/PNG/GIF 图片生成长边不超过 thumbnailSize 的 JPEG 缩略图；
+// 其他类型或像素数过大的图片返回 nil
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || int64(cfg.Width)*int64(cfg.Height) > thumbnailMaxPixels {
+		return nil, nil
+	}
+	if format != "jpeg" && format != "png" && format != "gif" {
+		return nil, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown 缩小图片使长边不超过 max，透明部分按白色背景合成。
+// 每个目标像素只在对应源区域内均匀取至多 thumbnailSamples² 个点求平均，耗时与源图大小无关
+func scaleDown(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > max {
+		tw, th = max, h*max/w
+	} else if h > w && h > max {
+		tw, th = w*max/h, max
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	at := pixelReader(src)
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		ny := sampleCount(y1 - y0)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			nx := sampleCount(x1 - x0)
+			var r, g, bl uint32
+			for i := 0; i < ny; i++ {
+				sy := y0 + (2*i+1)*(y1-y0)/(2*ny)
+				for j := 0; j < nx; j++ {
+					sx := x0 + (2*j+1)*(x1-x0)/(2*nx)
+					cr, cg, cb := at(sx, sy)
+					r += cr
+					g += cg
+					bl += cb
+				}
+			}
+			n := uint32(nx * ny)
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// sampleCount 返回长度为 span 的源区间上的采样点数
+func sampleCount(span int) int {
+	if span < 1 {
+		return 1
+	}
+	if span > thumbnailSamples {
+		return thumbnailSamples
+	}
+	return span
+}
+
+// pixelReader 返回读取单个像素（已合成到白色背景，16 位分量）的函数；
+// 常见的解码结果类型直接读取 Pix，其余类型回退到 At
+func pixelReader(src image.Image) func(x, y int) (r, g, b uint32) {
+	switch m := src.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32) {
+			yi, ci := m.YOffset(x, y), m.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(m.Y[yi], m.Cb[ci], m.Cr[ci])
+			return uint32(r) * 0x101, uint32(g) * 0x101, uint32(b) * 0x101
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32) {
+			p := m.Pix[m.PixOffset(x, y):]
+			// 预乘 alpha 的颜色叠加到白色背景
+			bg := uint32(0xff - p[3])
+			return (uint32(p[0]) + bg) * 0x101, (uint32(p[1]) + bg) * 0x101, (uint32(p[2]) + bg) * 0x101
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32) {
+			p := m.Pix[m.PixOffset(x, y):]
+			a := uint32(p[3])
+			bg := 0xff * (0xff - a)
+			return (uint32(p[0])*a + bg) * 0x101 / 0xff, (uint32(p[1])*a + bg) * 0x101 / 0xff, (uint32(p[2])*a + bg) * 0x101 / 0xff
+		}
+	}
+	return func(x, y int) (uint32, uint32, uint32) {
+		r, g, b, a := src.At(x, y).RGBA()
+		return r + 0xffff - a, g + 0xffff - a, b + 0xffff - a
+	}
+}
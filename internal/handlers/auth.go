@@ -4,6 +4,7 @@ import (
 	"account-service/internal/database"
 	"account-service/internal/middleware"
 	"account-service/internal/models"
+	"account-service/internal/storage"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,11 +17,13 @@ import (
 
 type AuthHandler struct {
 	db        *database.DB
+	files     storage.Storage
 	jwtSecret string
 }
 
-func NewAuthHandler(db *database.DB, jwtSecret string) *AuthHandler {
-	return &AuthHandler{db: db, jwtSecret: jwtSecret}
+// NewAuthHandler files 用于删除用户时清理其个人账本中的附件文件
+func NewAuthHandler(db *database.DB, files storage.Storage, jwtSecret string) *AuthHandler {
+	return &AuthHandler{db: db, files: files, jwtSecret: jwtSecret}
 }
 
 // RegisterStatus 查询是否允许注册（无用户时可注册）
@@ -106,6 +109,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
 		return
 	}
+	// 首个管理员接管升级前遗留的无归属记录
+	_ = h.db.BackfillRecordOwner()
 	_ = h.db.LogOperation(u.ID, u.Username, database.OpAddUser, "user", strconv.FormatInt(u.ID, 10), "首次注册", c.ClientIP(), c.GetHeader("User-Agent"))
 	token, _ := h.issueToken(u.ID, u.Username, u.Role)
 	c.JSON(http.StatusCreated, tokenResponse{
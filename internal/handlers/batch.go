@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Batch 批量创建、更新、删除记录 POST /api/records/batch
+// 先逐项校验，任一项不合法则不执行；全部合法后在同一事务中执行，任一项失败整体回滚。返回每一项的结果
+func (h *RecordHandler) Batch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	uid := middleware.GetUserID(c)
+	items := req.Operations
+	if req.Filter != nil {
+		if len(items) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "operations 与 filter 不能同时使用"})
+			return
+		}
+		if (req.Patch != nil) == req.Delete {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter 须配合 patch 或 delete 之一使用"})
+			return
+		}
+		f := req.Filter
+		f.Cursor, f.CursorMode, f.ExcludeTransfers = "", false, true
+		if err := f.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !f.HasFilter() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter 至少需要一个筛选条件"})
+			return
+		}
+		ids, err := h.db.ListIDs(ledgerID, f)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(ids) > models.BatchMaxItems {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "符合条件的记录超过 " + strconv.Itoa(models.BatchMaxItems) + " 条，请缩小范围"})
+			return
+		}
+		for _, id := range ids {
+			if req.Delete {
+				items = append(items, models.BatchOperation{Op: database.BatchDelete, ID: id})
+			} else {
+				patch := *req.Patch
+				items = append(items, models.BatchOperation{Op: database.BatchUpdate, ID: id, Patch: &patch})
+			}
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusOK, gin.H{"results": []*models.BatchItemResult{}, "count": 0})
+			return
+		}
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有要执行的操作"})
+		return
+	}
+	if len(items) > models.BatchMaxItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "单次最多 " + strconv.Itoa(models.BatchMaxItems) + " 项操作"})
+		return
+	}
+
+	baseCurrency := ""
+	ops := make([]*database.BatchOp, len(items))
+	results := make([]*models.BatchItemResult, len(items))
+	invalid := false
+	for i, it := range items {
+		results[i] = &models.BatchItemResult{Index: i, Op: it.Op, ID: it.ID, Status: models.BatchStatusNotApplied}
+		var err error
+		switch it.Op {
+		case database.BatchCreate:
+			if it.Record == nil {
+				err = badRecord("create 须提供 record")
+				break
+			}
+			if baseCurrency == "" {
+				st, e := h.db.GetUserSettings(uid)
+				if e != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": e.Error()})
+					return
+				}
+				baseCurrency = st.BaseCurrency
+			}
+			ops[i], err = h.prepareCreate(ledgerID, uid, baseCurrency, it.Record, false)
+		case database.BatchUpdate:
+			if it.ID <= 0 || it.Patch == nil {
+				err = badRecord("update 须提供 id 与 patch")
+				break
+			}
+			ops[i], err = h.prepareUpdate(ledgerID, it.ID, it.Patch, false)
+		case database.BatchDelete:
+			if it.ID <= 0 {
+				err = badRecord("delete 须提供 id")
+				break
+			}
+			ops[i] = &database.BatchOp{Op: database.BatchDelete, ID: it.ID}
+		default:
+			err = badRecord("op 须为 create、update 或 delete")
+		}
+		if err != nil {
+			_, msg := recordErrorStatus(err)
+			results[i].Status, results[i].Error = models.BatchStatusError, msg
+			invalid = true
+		}
+	}
+	if invalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "批量操作校验未通过，未执行任何操作", "results": results})
+		return
+	}
+
+	if failed, err := h.db.Batch(ledgerID, uid, ops); err != nil {
+		status, msg := recordErrorStatus(err)
+		if failed >= 0 {
+			results[failed].Status, results[failed].Error = models.BatchStatusError, msg
+		}
+		c.JSON(status, gin.H{"error": "批量操作失败，已全部回滚: " + msg, "results": results})
+		return
+	}
+
+	username, _ := c.Get("username")
+	for i, op := range ops {
+		results[i].Status, results[i].ID = models.BatchStatusOK, op.ID
+		action, detail := database.OpUpdateRecord, "批量"
+		switch op.Op {
+		case database.BatchCreate:
+			action, detail = database.OpCreateRecord, "批量 "+op.Record.Date+" "+op.Record.Amount.String()
+		case database.BatchDelete:
+			action = database.OpDeleteRecord
+		}
+		_ = h.db.LogOperation(uid, username.(string), action, "record", strconv.FormatInt(op.ID, 10), detail, c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "count": len(results)})
+}
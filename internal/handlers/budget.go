@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetHandler struct {
+	db *database.DB
+}
+
+func NewBudgetHandler(db *database.DB) *BudgetHandler {
+	return &BudgetHandler{db: db}
+}
+
+// ListBudgets 预算列表 GET /api/budgets
+func (h *BudgetHandler) ListBudgets(c *gin.Context) {
+	list, err := h.db.ListBudgets(middleware.GetLedgerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetBudget 获取预算
+func (h *BudgetHandler) GetBudget(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	b, err := h.db.GetBudget(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预算不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// CreateBudget 创建预算（category_id 为空时为总预算）
+func (h *BudgetHandler) CreateBudget(c *gin.Context) {
+	var req models.CreateBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidBudgetPeriod(req.Period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period 须为 monthly 或 yearly"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	uid := middleware.GetUserID(c)
+	if req.CategoryID != nil && *req.CategoryID == 0 {
+		req.CategoryID = nil
+	}
+	if req.CategoryID != nil {
+		cat, err := h.db.GetCategory(ledgerID, *req.CategoryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cat == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分类不存在"})
+			return
+		}
+		if cat.Type != models.CategoryTypeExpense {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "只能为支出分类设置预算"})
+			return
+		}
+	}
+	st, ok := userSettings(c, h.db)
+	if !ok {
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = st.BaseCurrency
+	}
+	currency, ok := models.NormalizeCurrency(req.Currency)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency 无效"})
+		return
+	}
+	if req.StartDate == "" {
+		req.StartDate = time.Now().Format("2006-01-02")
+	}
+	b := &models.Budget{
+		LedgerID:   ledgerID,
+		CategoryID: req.CategoryID,
+		Period:     req.Period,
+		Amount:     req.Amount,
+		Currency:   currency,
+		Rollover:   req.Rollover,
+		StartDate:  req.StartDate,
+	}
+	if !validateBudget(c, b) {
+		return
+	}
+	if err := h.db.CreateBudget(b, st.Calendar()); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			c.JSON(http.StatusConflict, gin.H{"error": "该分类已有同周期的预算"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreateBudget, "budget", strconv.FormatInt(b.ID, 10),
+		b.Period+" "+b.Amount.String(), c.ClientIP(), c.GetHeader("User-Agent"))
+	b, _ = h.db.GetBudget(ledgerID, b.ID)
+	c.JSON(http.StatusCreated, b)
+}
+
+// UpdateBudget 更新预算额度、结转或起始日
+func (h *BudgetHandler) UpdateBudget(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdateBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	b, err := h.db.GetBudget(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "预算不存在"})
+		return
+	}
+	if req.Amount != nil {
+		b.Amount = *req.Amount
+	}
+	if req.Rollover != nil {
+		b.Rollover = *req.Rollover
+	}
+	if req.StartDate != nil {
+		b.StartDate = *req.StartDate
+	}
+	if !validateBudget(c, b) {
+		return
+	}
+	st, ok := userSettings(c, h.db)
+	if !ok {
+		return
+	}
+	if err := h.db.UpdateBudget(ledgerID, b, st.Calendar()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "预算不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateBudget, "budget", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	b, _ = h.db.GetBudget(ledgerID, id)
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBudget 删除预算
+func (h *BudgetHandler) DeleteBudget(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.db.DeleteBudget(middleware.GetLedgerID(c), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "预算不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteBudget, "budget", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// Status 预算执行情况 GET /api/budgets/status?date=2024-03-15&periods=6
+// 返回各预算在 date（缺省今天）所在周期及之前共 periods 个周期（缺省 6，最多 36）的额度、已支出、剩余与使用率；
+// 周期按当前用户设置的每月起始日与财年起始月划分
+func (h *BudgetHandler) Status(c *gin.Context) {
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date 须为 YYYY-MM-DD"})
+		return
+	}
+	periods, _ := strconv.Atoi(c.DefaultQuery("periods", "6"))
+	if periods < 1 || periods > 36 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "periods 须在 1 到 36 之间"})
+		return
+	}
+	st, ok := userSettings(c, h.db)
+	if !ok {
+		return
+	}
+	list, err := h.db.BudgetStatus(middleware.GetLedgerID(c), date, periods, st.Calendar())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"date": date, "data": list})
+}
+
+// validateBudget 校验额度与起始日，失败时已写入响应
+func validateBudget(c *gin.Context, b *models.Budget) bool {
+	if b.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "预算额度须大于 0"})
+		return false
+	}
+	if _, err := time.Parse("2006-01-02", b.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date 须为 YYYY-MM-DD"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	db *database.DB
+}
+
+func NewCategoryHandler(db *database.DB) *CategoryHandler {
+	return &CategoryHandler{db: db}
+}
+
+// ListCategories 分类树 GET /api/categories?type=expense
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	typ := c.Query("type")
+	if typ != "" && !models.ValidCategoryType(typ) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 须为 income 或 expense"})
+		return
+	}
+	list, err := h.db.ListCategories(middleware.GetLedgerID(c), typ)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetCategory 获取分类
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	cat, err := h.db.GetCategory(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cat == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, cat)
+}
+
+// CreateCategory 创建分类，指定 parent_id 时为子分类
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req models.CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cat := &models.Category{
+		LedgerID:  middleware.GetLedgerID(c),
+		ParentID:  req.ParentID,
+		Name:      req.Name,
+		Type:      req.Type,
+		Icon:      req.Icon,
+		Color:     req.Color,
+		SortOrder: req.SortOrder,
+	}
+	if !h.validate(c, cat, false) {
+		return
+	}
+	if err := h.db.CreateCategory(cat); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreateCategory, "category", strconv.FormatInt(cat.ID, 10), "创建分类:"+cat.Name, c.ClientIP(), c.GetHeader("User-Agent"))
+	cat, _ = h.db.GetCategory(cat.LedgerID, cat.ID)
+	c.JSON(http.StatusCreated, cat)
+}
+
+// UpdateCategory 更新分类（改名会同步到已有记录）
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	cat, err := h.db.GetCategory(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cat == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+	if req.Name != nil {
+		cat.Name = *req.Name
+	}
+	if req.Type != nil {
+		cat.Type = *req.Type
+	}
+	if req.ParentID != nil {
+		cat.ParentID = req.ParentID
+		if *req.ParentID == 0 {
+			cat.ParentID = nil
+		}
+	}
+	if req.Icon != nil {
+		cat.Icon = *req.Icon
+	}
+	if req.Color != nil {
+		cat.Color = *req.Color
+	}
+	if req.SortOrder != nil {
+		cat.SortOrder = *req.SortOrder
+	}
+	if !h.validate(c, cat, true) {
+		return
+	}
+	if err := h.db.UpdateCategory(ledgerID, cat); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateCategory, "category", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	cat, _ = h.db.GetCategory(ledgerID, id)
+	c.JSON(http.StatusOK, cat)
+}
+
+// DeleteCategory 删除分类 DELETE /api/categories/:id?merge_into=
+// 分类下有记录时须通过 merge_into 指定合并到的分类，记录将改挂到该分类
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	var target *models.Category
+	if v := c.Query("merge_into"); v != "" {
+		tid, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || tid == id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "merge_into 无效"})
+			return
+		}
+		if target, err = h.db.GetCategory(ledgerID, tid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if target == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "合并目标分类不存在"})
+			return
+		}
+	}
+	if err := h.db.DeleteCategory(ledgerID, id, target); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		case errors.Is(err, database.ErrCategoryInUse), errors.Is(err, database.ErrCategoryHasChildren):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	detail := ""
+	if target != nil {
+		detail = "合并到:" + target.Name
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteCategory, "category", strconv.FormatInt(id, 10), detail, c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// validate 校验名称、类型与父分类（最多两级，子分类类型沿用父分类），失败时已写入响应
+func (h *CategoryHandler) validate(c *gin.Context, cat *models.Category, existing bool) bool {
+	cat.Name = strings.TrimSpace(cat.Name)
+	if cat.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name 不能为空"})
+		return false
+	}
+	if cat.ParentID == nil || *cat.ParentID == 0 {
+		cat.ParentID = nil
+		if !models.ValidCategoryType(cat.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type 须为 income 或 expense"})
+			return false
+		}
+		return true
+	}
+	if existing && *cat.ParentID == cat.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能以自身为父分类"})
+		return false
+	}
+	parent, err := h.db.GetCategory(cat.LedgerID, *cat.ParentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if parent == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "父分类不存在"})
+		return false
+	}
+	if parent.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类最多两级"})
+		return false
+	}
+	if existing {
+		list, err := h.db.ListCategories(cat.LedgerID, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		for _, top := range list {
+			if top.ID == cat.ID && len(top.Children) > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "含子分类的分类不能再设父分类"})
+				return false
+			}
+		}
+	}
+	cat.Type = parent.Type
+	return true
+}
+
+// writeError 写入保存分类时的错误，同级重名返回 409
+func (h *CategoryHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+	case strings.Contains(err.Error(), "UNIQUE"):
+		c.JSON(http.StatusConflict, gin.H{"error": "同级下已有同名分类"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateHandler struct {
+	db *database.DB
+}
+
+func NewExchangeRateHandler(db *database.DB) *ExchangeRateHandler {
+	return &ExchangeRateHandler{db: db}
+}
+
+// ListRates 汇率列表 GET /api/exchange-rates?from_currency=USD&to_currency=CNY&start_date=&end_date=
+func (h *ExchangeRateHandler) ListRates(c *gin.Context) {
+	var q models.ExchangeRateQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.FromCurrency = strings.ToUpper(q.FromCurrency)
+	q.ToCurrency = strings.ToUpper(q.ToCurrency)
+	list, err := h.db.ListExchangeRates(&q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// SaveRates 批量录入汇率（同日同币种对覆盖）POST /api/exchange-rates
+func (h *ExchangeRateHandler) SaveRates(c *gin.Context) {
+	var req models.SaveExchangeRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range req.Rates {
+		if err := normalizeRate(&req.Rates[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("第 %d 条: %s", i+1, err)})
+			return
+		}
+	}
+	h.save(c, req.Rates)
+}
+
+// ImportRates 导入 CSV 汇率 POST /api/exchange-rates/import
+// 每行 date,from_currency,to_currency,rate，首行表头可选；支持 multipart 的 file 字段或直接以请求体上传
+func (h *ExchangeRateHandler) ImportRates(c *gin.Context) {
+	var src io.Reader = c.Request.Body
+	if fh, err := c.FormFile("file"); err == nil {
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		src = f
+	}
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = 4
+	r.TrimLeadingSpace = true
+	var rates []models.ExchangeRateInput
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "date") {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("第 %d 行: rate 无效", line)})
+			return
+		}
+		in := models.ExchangeRateInput{Date: strings.TrimSpace(rec[0]), FromCurrency: rec[1], ToCurrency: rec[2], Rate: rate}
+		if err := normalizeRate(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("第 %d 行: %s", line, err)})
+			return
+		}
+		rates = append(rates, in)
+	}
+	if len(rates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有可导入的汇率"})
+		return
+	}
+	h.save(c, rates)
+}
+
+// DeleteRate 删除汇率 DELETE /api/exchange-rates/:id
+func (h *ExchangeRateHandler) DeleteRate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.db.DeleteExchangeRate(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "汇率不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteRate, "exchange_rate", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func (h *ExchangeRateHandler) save(c *gin.Context, rates []models.ExchangeRateInput) {
+	if err := h.db.SaveExchangeRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpSaveRates, "exchange_rate", "",
+		fmt.Sprintf("%d 条", len(rates)), c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"saved": len(rates)})
+}
+
+// normalizeRate 校验日期、币种与汇率，并规范化币种代码
+func normalizeRate(r *models.ExchangeRateInput) error {
+	if _, err := time.Parse("2006-01-02", r.Date); err != nil {
+		return errors.New("date 须为 YYYY-MM-DD")
+	}
+	from, ok1 := models.NormalizeCurrency(r.FromCurrency)
+	to, ok2 := models.NormalizeCurrency(r.ToCurrency)
+	if !ok1 || !ok2 {
+		return errors.New("币种代码无效")
+	}
+	if from == to {
+		return errors.New("from_currency 与 to_currency 不能相同")
+	}
+	if r.Rate <= 0 {
+		return errors.New("rate 须大于 0")
+	}
+	r.FromCurrency, r.ToCurrency = from, to
+	return nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/forecast"
+	"account-service/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ForecastHandler struct {
+	db         *database.DB
+	forecaster *forecast.Forecaster
+}
+
+func NewForecastHandler(db *database.DB) *ForecastHandler {
+	return &ForecastHandler{db: db, forecaster: forecast.New(db)}
+}
+
+// Forecast 收支预测 GET /api/forecast?date=2024-03-15&history=180
+// 以 date（缺省今天）为止的实际数据预测本月与本财年末的收支与结余，history 为拟合日常收支的历史天数（缺省 180，28 到 730）
+func (h *ForecastHandler) Forecast(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.DefaultQuery("date", time.Now().Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date 须为 YYYY-MM-DD"})
+		return
+	}
+	history, _ := strconv.Atoi(c.DefaultQuery("history", "180"))
+	if history < 28 || history > 730 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "history 须在 28 到 730 之间"})
+		return
+	}
+	st, ok := userSettings(c, h.db)
+	if !ok {
+		return
+	}
+	fc, err := h.forecaster.Project(middleware.GetLedgerID(c), date, st.Calendar(), st.BaseCurrency, history)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fc)
+}
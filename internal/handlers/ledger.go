@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	db *database.DB
+}
+
+func NewLedgerHandler(db *database.DB) *LedgerHandler {
+	return &LedgerHandler{db: db}
+}
+
+func validLedgerRole(role string) bool {
+	return models.LedgerRoleRank(role) > 0
+}
+
+// ListLedgers 当前用户参与的账本 GET /api/ledgers
+func (h *LedgerHandler) ListLedgers(c *gin.Context) {
+	uid := middleware.GetUserID(c)
+	// 确保用户至少拥有一个默认账本
+	if _, err := h.db.DefaultLedgerID(uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	list, err := h.db.ListLedgers(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateLedger 创建账本，创建者为所有者 POST /api/ledgers
+func (h *LedgerHandler) CreateLedger(c *gin.Context) {
+	var req models.CreateLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	l := &models.Ledger{Name: req.Name}
+	if err := h.db.CreateLedger(l, uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreateLedger, "ledger", strconv.FormatInt(l.ID, 10), "创建账本:"+l.Name, c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusCreated, l)
+}
+
+// ListMembers 账本成员列表 GET /api/ledgers/:ledger_id/members
+func (h *LedgerHandler) ListMembers(c *gin.Context) {
+	list, err := h.db.ListLedgerMembers(middleware.GetLedgerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// InviteMember 邀请用户加入账本（所有者） POST /api/ledgers/:ledger_id/members
+func (h *LedgerHandler) InviteMember(c *gin.Context) {
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.LedgerRoleViewer
+	}
+	if !validLedgerRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 须为 owner、editor 或 viewer"})
+		return
+	}
+	u, err := h.db.GetUserByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	m, err := h.db.AddLedgerMember(ledgerID, u.ID, req.Role)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "该用户已是账本成员"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpInviteMember, "ledger", strconv.FormatInt(ledgerID, 10), "邀请成员:"+u.Username+"("+req.Role+")", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusCreated, m)
+}
+
+// UpdateMemberRole 修改成员角色（所有者） PUT /api/ledgers/:ledger_id/members/:user_id
+func (h *LedgerHandler) UpdateMemberRole(c *gin.Context) {
+	memberID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validLedgerRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role 须为 owner、editor 或 viewer"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	if err := h.db.UpdateLedgerMemberRole(ledgerID, memberID, req.Role); err != nil {
+		h.memberError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateMember, "ledger", strconv.FormatInt(ledgerID, 10), "成员 "+c.Param("user_id")+" 角色改为 "+req.Role, c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "已更新"})
+}
+
+// RemoveMember 移除账本成员（所有者） DELETE /api/ledgers/:ledger_id/members/:user_id
+func (h *LedgerHandler) RemoveMember(c *gin.Context) {
+	memberID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	if err := h.db.RemoveLedgerMember(ledgerID, memberID); err != nil {
+		h.memberError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpRemoveMember, "ledger", strconv.FormatInt(ledgerID, 10), "移除成员 "+c.Param("user_id"), c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "已移除"})
+}
+
+func (h *LedgerHandler) memberError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "成员不存在"})
+	case errors.Is(err, database.ErrLastOwner):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
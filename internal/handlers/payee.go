@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayeeHandler struct {
+	db *database.DB
+}
+
+func NewPayeeHandler(db *database.DB) *PayeeHandler {
+	return &PayeeHandler{db: db}
+}
+
+// ListPayees 交易对方列表与自动补全 GET /api/payees?q=cost&limit=10
+// q 按名称或别名前缀匹配（不区分大小写），结果按使用频率与最近使用排序
+func (h *PayeeHandler) ListPayees(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	list, err := h.db.ListPayees(middleware.GetLedgerID(c), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetPayee 获取交易对方
+func (h *PayeeHandler) GetPayee(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	p, err := h.db.GetPayee(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易对方不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePayee 创建交易对方
+func (h *PayeeHandler) CreatePayee(c *gin.Context) {
+	var req models.CreatePayeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p := &models.Payee{LedgerID: middleware.GetLedgerID(c), Name: req.Name, Aliases: req.Aliases}
+	if !validatePayee(c, p) {
+		return
+	}
+	if err := h.db.CreatePayee(p); err != nil {
+		writePayeeError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreatePayee, "payee", strconv.FormatInt(p.ID, 10), "创建交易对方:"+p.Name, c.ClientIP(), c.GetHeader("User-Agent"))
+	p, _ = h.db.GetPayee(p.LedgerID, p.ID)
+	c.JSON(http.StatusCreated, p)
+}
+
+// UpdatePayee 修改交易对方名称与别名（改名会同步到已有记录）
+func (h *PayeeHandler) UpdatePayee(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdatePayeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	p, err := h.db.GetPayee(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易对方不存在"})
+		return
+	}
+	if req.Name != nil {
+		p.Name = *req.Name
+	}
+	if req.Aliases != nil {
+		p.Aliases = *req.Aliases
+	}
+	if !validatePayee(c, p) {
+		return
+	}
+	if err := h.db.UpdatePayee(ledgerID, p); err != nil {
+		writePayeeError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdatePayee, "payee", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	p, _ = h.db.GetPayee(ledgerID, id)
+	c.JSON(http.StatusOK, p)
+}
+
+// DeletePayee 删除交易对方，已关联的记录保留名称并解除关联
+func (h *PayeeHandler) DeletePayee(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.db.DeletePayee(middleware.GetLedgerID(c), id); err != nil {
+		writePayeeError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeletePayee, "payee", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// validatePayee 规范化并校验名称与别名，失败时已写入响应
+func validatePayee(c *gin.Context, p *models.Payee) bool {
+	name, err := models.NormalizePayeeName(p.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	aliases, err := models.NormalizeAliases(name, p.Aliases)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	p.Name, p.Aliases = name, aliases
+	return true
+}
+
+func writePayeeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易对方不存在"})
+	case errors.Is(err, database.ErrPayeeNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -4,41 +4,54 @@ import (
 	"account-service/internal/database"
 	"account-service/internal/middleware"
 	"account-service/internal/models"
+	"account-service/internal/storage"
 	"database/sql"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RecordHandler struct {
-	db *database.DB
+	db    *database.DB
+	files storage.Storage // 附件存储
 }
 
-func NewRecordHandler(db *database.DB) *RecordHandler {
-	return &RecordHandler{db: db}
+func NewRecordHandler(db *database.DB, files storage.Storage) *RecordHandler {
+	return &RecordHandler{db: db, files: files}
 }
 
-// ListRecords 查询记录（支持日期范围和关键字）
-// GET /api/records?start_date=2024-01-01&end_date=2024-12-31&keyword=餐饮&page=1&page_size=20
+// ListRecords 查询记录（支持日期范围、关键字、分类和标签）
+// GET /api/records?ledger_id=1&start_date=2024-01-01&end_date=2024-12-31&keyword=餐饮&tags=a,b&tag_mode=all&page=1&page_size=20
+// 带 cursor 参数时为游标分页：首页传 cursor=，之后传上一页返回的 next_cursor，next_cursor 为空表示没有更多
 func (h *RecordHandler) ListRecords(c *gin.Context) {
 	var params models.QueryParams
 	if err := c.ShouldBindQuery(&params); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	list, total, err := h.db.List(&params)
+	_, params.CursorMode = c.GetQuery("cursor")
+	if err := params.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, total, next, err := h.db.List(middleware.GetLedgerID(c), &params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"data":  list,
-		"total": total,
-		"page":  params.Page,
-		"size":  params.PageSize,
-	})
+	resp := gin.H{"data": list, "size": params.PageSize}
+	if params.CursorMode {
+		resp["next_cursor"] = next
+	} else {
+		resp["page"] = params.Page
+	}
+	if params.WantTotal() {
+		resp["total"] = total
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetRecord 根据ID获取单条记录
@@ -48,7 +61,7 @@ func (h *RecordHandler) GetRecord(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	r, err := h.db.GetByID(id)
+	r, err := h.db.GetByID(middleware.GetLedgerID(c), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -57,6 +70,7 @@ func (h *RecordHandler) GetRecord(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
 		return
 	}
+	setETag(c, r)
 	c.JSON(http.StatusOK, r)
 }
 
@@ -67,20 +81,27 @@ func (h *RecordHandler) CreateRecord(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	r := &models.Record{
-		Date:        req.Date,
-		Amount:      req.Amount,
-		Category:    req.Category,
-		Description: req.Description,
+	uid := middleware.GetUserID(c)
+	st, err := h.db.GetUserSettings(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	op, err := h.prepareCreate(middleware.GetLedgerID(c), uid, st.BaseCurrency, &req, true)
+	if err != nil {
+		writeRecordError(c, err)
+		return
 	}
+	r := op.Record
 	if err := h.db.Create(r); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	uid := middleware.GetUserID(c)
 	username, _ := c.Get("username")
 	_ = h.db.LogOperation(uid, username.(string), database.OpCreateRecord, "record", strconv.FormatInt(r.ID, 10),
-		req.Date+" "+strconv.FormatFloat(req.Amount, 'f', 2, 64), c.ClientIP(), c.GetHeader("User-Agent"))
+		req.Date+" "+req.Amount.String(), c.ClientIP(), c.GetHeader("User-Agent"))
+	r, _ = h.db.GetByID(r.LedgerID, r.ID)
+	setETag(c, r)
 	c.JSON(http.StatusCreated, r)
 }
 
@@ -96,38 +117,373 @@ func (h *RecordHandler) UpdateRecord(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.db.Update(id, &req); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ledgerID := middleware.GetLedgerID(c)
+	op, err := h.prepareUpdate(ledgerID, id, &req, true)
+	if err != nil {
+		writeRecordError(c, err)
 		return
 	}
 	uid := middleware.GetUserID(c)
+	rev, err := h.db.Update(ledgerID, id, uid, ifMatchVersion(c), op.Patch)
+	if err != nil {
+		h.writeUpdateError(c, ledgerID, id, err)
+		return
+	}
 	username, _ := c.Get("username")
-	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateRecord, "record", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
-	r, _ := h.db.GetByID(id)
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateRecord, "record", strconv.FormatInt(id, 10),
+		changedFields(rev), c.ClientIP(), c.GetHeader("User-Agent"))
+	r, _ := h.db.GetByID(ledgerID, id)
+	setETag(c, r)
 	c.JSON(http.StatusOK, r)
 }
 
-// DeleteRecord 删除记录
+// DeleteRecord 删除记录，移入回收站（转账分录会连同整笔转账一起删除）
 func (h *RecordHandler) DeleteRecord(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := h.db.Delete(id); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ledgerID := middleware.GetLedgerID(c)
+	uid := middleware.GetUserID(c)
+	if err := h.db.Delete(ledgerID, id, uid, ifMatchVersion(c)); err != nil {
+		h.writeUpdateError(c, ledgerID, id, err)
 		return
 	}
-	uid := middleware.GetUserID(c)
 	username, _ := c.Get("username")
 	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteRecord, "record", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 }
+
+// ifMatchVersion 解析 If-Match 请求头中的版本号（ETag 形如 "3"，可带 W/ 前缀）：
+// 未提供或为 * 时返回 0 表示不校验；无法解析时返回 -1，与任何版本都不匹配
+func ifMatchVersion(c *gin.Context) int64 {
+	tag := strings.TrimSpace(c.GetHeader("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0
+	}
+	v, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+	if err != nil || v <= 0 {
+		return -1
+	}
+	return v
+}
+
+// setETag 以记录版本号设置 ETag 响应头
+func setETag(c *gin.Context, r *models.Record) {
+	if r != nil {
+		c.Header("ETag", `"`+strconv.FormatInt(r.Version, 10)+`"`)
+	}
+}
+
+// writeUpdateError 写入修改记录失败的响应：版本冲突时返回 412 及服务端当前记录（附 ETag），其余同 writeRecordError
+func (h *RecordHandler) writeUpdateError(c *gin.Context, ledgerID, id int64, err error) {
+	if !errors.Is(err, database.ErrVersionConflict) {
+		writeRecordError(c, err)
+		return
+	}
+	cur, _ := h.db.GetByID(ledgerID, id)
+	if cur == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	setETag(c, cur)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error(), "current": cur})
+}
+
+// recordError 记录校验失败，Status 为应返回的 HTTP 状态码
+type recordError struct {
+	Status int
+	Msg    string
+}
+
+func (e *recordError) Error() string { return e.Msg }
+
+func badRecord(msg string) error {
+	return &recordError{Status: http.StatusBadRequest, Msg: msg}
+}
+
+// writeRecordError 按错误类型写入响应
+func writeRecordError(c *gin.Context, err error) {
+	status, msg := recordErrorStatus(err)
+	c.JSON(status, gin.H{"error": msg})
+}
+
+// recordErrorStatus 错误对应的状态码与提示：校验错误、记录不存在、转账分录不可修改、拆分合计不符，其余为 500
+func recordErrorStatus(err error) (int, string) {
+	var re *recordError
+	switch {
+	case errors.As(err, &re):
+		return re.Status, re.Msg
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, "record not found"
+	case errors.Is(err, database.ErrTransferLeg), errors.Is(err, database.ErrSplitMismatch):
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// prepareCreate 校验创建请求并构造记录。create 为 false 时不自动创建分类，
+// 按名称找不到的分类记入返回项的 CategoryName，由批量操作在事务内创建
+func (h *RecordHandler) prepareCreate(ledgerID, uid int64, baseCurrency string, req *models.CreateRecordRequest, create bool) (*database.BatchOp, error) {
+	if req.Date == "" {
+		return nil, badRecord("date 不能为空")
+	}
+	if req.Amount == 0 {
+		return nil, badRecord("amount 不能为 0")
+	}
+	currency, err := recordCurrency(h.db, ledgerID, req.AccountID, req.Currency, baseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Splits) > 0 && hasCategory(req.CategoryID, &req.Category) {
+		return nil, errSplitCategory
+	}
+	splits, err := h.resolveSplits(ledgerID, req.Amount, req.Splits, create)
+	if err != nil {
+		return nil, err
+	}
+	categoryID, category, err := lookupCategory(h.db, ledgerID, req.CategoryID, req.Category, req.Amount, create)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, badRecord(err.Error())
+	}
+	if err := h.checkPayee(ledgerID, req.PayeeID); err != nil {
+		return nil, err
+	}
+	op := &database.BatchOp{Op: database.BatchCreate, Record: &models.Record{
+		LedgerID:    ledgerID,
+		UserID:      uid,
+		Date:        req.Date,
+		Amount:      req.Amount,
+		Currency:    currency,
+		CategoryID:  categoryID,
+		Category:    category,
+		Description: req.Description,
+		Payee:       strings.TrimSpace(req.Payee),
+		PayeeID:     req.PayeeID,
+		AccountID:   req.AccountID,
+		Tags:        tags,
+		Splits:      splits,
+	}}
+	if categoryID == nil && category != "" {
+		op.CategoryName, op.CategoryType = category, categoryTypeFor(req.Amount)
+	}
+	return op, nil
+}
+
+// prepareUpdate 校验更新请求并补全分类与币种，create 含义同 prepareCreate
+func (h *RecordHandler) prepareUpdate(ledgerID, id int64, req *models.UpdateRecordRequest, create bool) (*database.BatchOp, error) {
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			return nil, badRecord(err.Error())
+		}
+		req.Tags = &tags
+	}
+	if req.Payee != nil {
+		payee := strings.TrimSpace(*req.Payee)
+		req.Payee = &payee
+	}
+	if err := h.checkPayee(ledgerID, req.PayeeID); err != nil {
+		return nil, err
+	}
+	op := &database.BatchOp{Op: database.BatchUpdate, ID: id, Patch: req}
+	var cur *models.Record
+	if req.AccountID != nil || req.Currency != nil || req.CategoryID != nil || req.Category != nil || req.Amount != nil || req.Splits != nil {
+		var err error
+		if cur, err = h.db.GetByID(ledgerID, id); err != nil {
+			return nil, err
+		}
+		if cur == nil {
+			return nil, sql.ErrNoRows
+		}
+	}
+	if req.Splits != nil || req.Amount != nil {
+		amount, splits := cur.Amount, cur.Splits
+		if req.Amount != nil {
+			amount = *req.Amount
+		}
+		if req.Splits != nil {
+			resolved, err := h.resolveSplits(ledgerID, amount, *req.Splits, create)
+			if err != nil {
+				return nil, err
+			}
+			req.Splits, splits = &resolved, resolved
+		} else if len(splits) > 0 && amount != cur.Amount {
+			return nil, badRecord(database.ErrSplitMismatch.Error())
+		}
+		if len(splits) > 0 && hasCategory(req.CategoryID, req.Category) {
+			return nil, errSplitCategory
+		}
+	}
+	if req.CategoryID != nil || req.Category != nil {
+		amount := cur.Amount
+		if req.Amount != nil {
+			amount = *req.Amount
+		}
+		name := ""
+		if req.Category != nil {
+			name = *req.Category
+		}
+		categoryID, category, err := lookupCategory(h.db, ledgerID, req.CategoryID, name, amount, create)
+		if err != nil {
+			return nil, err
+		}
+		if categoryID == nil && category != "" {
+			op.CategoryName, op.CategoryType = category, categoryTypeFor(amount)
+		}
+		if categoryID == nil {
+			categoryID = new(int64)
+		}
+		req.CategoryID, req.Category = categoryID, &category
+	}
+	if req.AccountID != nil || req.Currency != nil {
+		// 以修改后的账户与币种校验；仅改账户时币种随账户
+		accountID := cur.AccountID
+		if req.AccountID != nil {
+			accountID = req.AccountID
+		}
+		currency := ""
+		if req.Currency != nil {
+			currency = *req.Currency
+		} else if req.AccountID == nil {
+			currency = cur.Currency
+		}
+		currency, err := recordCurrency(h.db, ledgerID, accountID, currency, cur.Currency)
+		if err != nil {
+			return nil, err
+		}
+		req.Currency = &currency
+	}
+	return op, nil
+}
+
+// checkPayee 校验 payee_id 属于当前账本，nil 或 0 时不校验
+func (h *RecordHandler) checkPayee(ledgerID int64, payeeID *int64) error {
+	if payeeID == nil || *payeeID == 0 {
+		return nil
+	}
+	p, err := h.db.GetPayee(ledgerID, *payeeID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return badRecord("交易对方不存在")
+	}
+	return nil
+}
+
+var errSplitCategory = badRecord("拆分记录的分类由各拆分行指定，不能同时设置 category（取消拆分请传 splits: []）")
+
+// hasCategory 请求是否给出了非空的分类
+func hasCategory(categoryID *int64, name *string) bool {
+	return (categoryID != nil && *categoryID != 0) || (name != nil && strings.TrimSpace(*name) != "")
+}
+
+// resolveSplits 校验拆分行并确定各行分类（规则同记录的分类，create 含义同 prepareCreate），返回新的拆分行
+func (h *RecordHandler) resolveSplits(ledgerID int64, total models.Money, splits []*models.Split, create bool) ([]*models.Split, error) {
+	if err := models.ValidateSplits(total, splits); err != nil {
+		return nil, badRecord(err.Error())
+	}
+	resolved := make([]*models.Split, len(splits))
+	for i, s := range splits {
+		categoryID, category, err := lookupCategory(h.db, ledgerID, s.CategoryID, s.Category, s.Amount, create)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = &models.Split{CategoryID: categoryID, Category: category, Amount: s.Amount, Note: strings.TrimSpace(s.Note)}
+	}
+	return resolved, nil
+}
+
+// categoryTypeFor 按金额正负推断自动创建分类的类型
+func categoryTypeFor(amount models.Money) string {
+	if amount > 0 {
+		return models.CategoryTypeIncome
+	}
+	return models.CategoryTypeExpense
+}
+
+// resolveCategory 确定记录的分类：给出 categoryID 时校验其属于当前账本（0 表示不分类）；
+// 否则按名称匹配分类，不存在时按金额正负创建收入或支出分类，名称为空表示不分类。失败时已写入响应
+func resolveCategory(c *gin.Context, db *database.DB, categoryID *int64, name string, amount models.Money) (*int64, string, bool) {
+	id, category, err := lookupCategory(db, middleware.GetLedgerID(c), categoryID, name, amount, true)
+	if err != nil {
+		writeRecordError(c, err)
+		return nil, "", false
+	}
+	return id, category, true
+}
+
+// lookupCategory 同 resolveCategory；create 为 false 时按名称找不到不创建，返回空 ID 与去除空白后的名称
+func lookupCategory(db *database.DB, ledgerID int64, categoryID *int64, name string, amount models.Money, create bool) (*int64, string, error) {
+	var cat *models.Category
+	var err error
+	switch {
+	case categoryID != nil && *categoryID != 0:
+		if cat, err = db.GetCategory(ledgerID, *categoryID); err == nil && cat == nil {
+			return nil, "", badRecord("分类不存在")
+		}
+	case categoryID != nil || strings.TrimSpace(name) == "":
+		return nil, "", nil
+	case create:
+		cat, err = db.FindOrCreateCategory(ledgerID, name, categoryTypeFor(amount))
+	default:
+		if cat, err = db.FindCategory(ledgerID, name); err == nil && cat == nil {
+			return nil, strings.TrimSpace(name), nil
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &cat.ID, cat.Name, nil
+}
+
+// checkAccount 校验记录关联的账户属于当前账本且未归档，并确定记录币种：
+// 关联账户时币种须与账户一致（未指定则取账户币种），未关联账户且未指定时取 fallback。失败时已写入响应
+func checkAccount(c *gin.Context, db *database.DB, accountID *int64, currency *string, fallback string) bool {
+	cur, err := recordCurrency(db, middleware.GetLedgerID(c), accountID, *currency, fallback)
+	if err != nil {
+		writeRecordError(c, err)
+		return false
+	}
+	*currency = cur
+	return true
+}
+
+// recordCurrency 同 checkAccount，返回确定的币种
+func recordCurrency(db *database.DB, ledgerID int64, accountID *int64, currency, fallback string) (string, error) {
+	if currency != "" {
+		cur, ok := models.NormalizeCurrency(currency)
+		if !ok {
+			return "", badRecord("currency 无效")
+		}
+		currency = cur
+	}
+	if accountID == nil || *accountID == 0 {
+		if currency == "" {
+			currency = fallback
+		}
+		return currency, nil
+	}
+	a, err := db.GetAccount(ledgerID, *accountID)
+	if err != nil {
+		return "", err
+	}
+	if a == nil {
+		return "", badRecord("账户不存在")
+	}
+	if a.Archived {
+		return "", badRecord("账户已归档")
+	}
+	if currency == "" {
+		currency = a.Currency
+	} else if currency != a.Currency {
+		return "", badRecord("记录币种须与账户币种一致")
+	}
+	return currency, nil
+}
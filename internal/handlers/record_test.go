@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 以临时数据库注册记录与转账路由，请求均以 1 号用户在 1 号账本中执行
+func newTestRouter(t *testing.T) (*gin.Engine, *database.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(1))
+		c.Set("username", "alice")
+		c.Set("ledger_id", int64(1))
+	})
+	records := NewRecordHandler(db, nil)
+	r.POST("/records", records.CreateRecord)
+	r.PUT("/records/:id", records.UpdateRecord)
+	r.DELETE("/records/:id", records.DeleteRecord)
+	transfers := NewTransferHandler(db)
+	r.POST("/transfers", transfers.CreateTransfer)
+	r.PUT("/transfers/:id", transfers.UpdateTransfer)
+	r.DELETE("/transfers/:id", transfers.DeleteTransfer)
+	return r, db
+}
+
+func serve(r http.Handler, method, path, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// preconditionFailed 断言响应为 412，且 current 为服务端当前版本、ETag 与之一致
+func preconditionFailed(t *testing.T, w *httptest.ResponseRecorder, wantVersion int64) map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Error   string                 `json:"error"`
+		Current map[string]interface{} `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == "" || resp.Current == nil {
+		t.Fatalf("412 body = %s, want error and current", w.Body)
+	}
+	if v, _ := resp.Current["version"].(float64); int64(v) != wantVersion {
+		t.Errorf("current.version = %v, want %d", resp.Current["version"], wantVersion)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"`+strconv.FormatInt(wantVersion, 10)+`"` {
+		t.Errorf("ETag = %q, want version %d", etag, wantVersion)
+	}
+	return resp.Current
+}
+
+func TestRecordIfMatch(t *testing.T) {
+	r, db := newTestRouter(t)
+	if w := serve(r, http.MethodPost, "/records", "", `{"date":"2024-03-01","amount":-10,"description":"lunch"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", w.Code, w.Body)
+	}
+	w := serve(r, http.MethodPut, "/records/1", `"1"`, `{"description":"brunch"}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("update = %d, ETag %q: %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+
+	// 持有旧版本的修改被拒绝，返回的 current 为他人修改后的记录
+	cur := preconditionFailed(t, serve(r, http.MethodPut, "/records/1", `"1"`, `{"description":"dinner"}`), 2)
+	if cur["description"] != "brunch" {
+		t.Errorf("current.description = %v, want brunch", cur["description"])
+	}
+	preconditionFailed(t, serve(r, http.MethodPut, "/records/1", `"abc"`, `{"description":"dinner"}`), 2)
+	preconditionFailed(t, serve(r, http.MethodDelete, "/records/1", `W/"1"`, ""), 2)
+	if rec, _ := db.GetByID(1, 1); rec == nil || rec.Description != "brunch" {
+		t.Errorf("record after rejected writes = %+v", rec)
+	}
+
+	if w := serve(r, http.MethodPut, "/records/1", `*`, `{"description":"dinner"}`); w.Code != http.StatusOK {
+		t.Errorf("update with If-Match * = %d", w.Code)
+	}
+	if w := serve(r, http.MethodDelete, "/records/1", `"3"`, ""); w.Code != http.StatusOK {
+		t.Errorf("delete with current version = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestTransferIfMatch(t *testing.T) {
+	r, db := newTestRouter(t)
+	for _, name := range []string{"cash", "bank"} {
+		if err := db.CreateAccount(&models.Account{LedgerID: 1, Name: name, Currency: "CNY"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := serve(r, http.MethodPost, "/transfers", "", `{"date":"2024-03-01","amount":10,"from_account_id":1,"to_account_id":2}`); w.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodPut, "/transfers/1", `"1"`, `{"description":"rent"}`); w.Code != http.StatusOK {
+		t.Fatalf("update = %d: %s", w.Code, w.Body)
+	}
+	cur := preconditionFailed(t, serve(r, http.MethodPut, "/transfers/1", `"1"`, `{"description":"savings"}`), 2)
+	if cur["description"] != "rent" {
+		t.Errorf("current.description = %v, want rent", cur["description"])
+	}
+	preconditionFailed(t, serve(r, http.MethodDelete, "/transfers/1", `"1"`, ""), 2)
+	if tr, _ := db.GetTransfer(1, 1); tr == nil {
+		t.Fatal("transfer deleted despite stale If-Match")
+	}
+	if w := serve(r, http.MethodDelete, "/transfers/1", `"2"`, ""); w.Code != http.StatusOK {
+		t.Errorf("delete with current version = %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recurringMaxBackfill start_date 最早可设为多少天前
+const recurringMaxBackfill = 366
+
+type RecurringHandler struct {
+	db *database.DB
+}
+
+func NewRecurringHandler(db *database.DB) *RecurringHandler {
+	return &RecurringHandler{db: db}
+}
+
+// ListRecurringRules 周期记账规则列表 GET /api/recurring
+func (h *RecurringHandler) ListRecurringRules(c *gin.Context) {
+	list, err := h.db.ListRecurringRules(middleware.GetLedgerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetRecurringRule 获取周期记账规则
+func (h *RecurringHandler) GetRecurringRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	r, err := h.db.GetRecurringRule(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if r == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "周期记账规则不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// CreateRecurringRule 创建周期记账规则，起始日早于今天时调度器会补记已过去的周期
+func (h *RecurringHandler) CreateRecurringRule(c *gin.Context) {
+	var req models.CreateRecurringRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	st, err := h.db.GetUserSettings(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	currency := req.Currency
+	if !checkAccount(c, h.db, req.AccountID, &currency, st.BaseCurrency) {
+		return
+	}
+	categoryID, category, ok := resolveCategory(c, h.db, req.CategoryID, req.Category, req.Amount)
+	if !ok {
+		return
+	}
+	if req.Interval == 0 {
+		req.Interval = 1
+	}
+	if req.AccountID != nil && *req.AccountID == 0 {
+		req.AccountID = nil
+	}
+	r := &models.RecurringRule{
+		LedgerID:    middleware.GetLedgerID(c),
+		UserID:      uid,
+		Name:        strings.TrimSpace(req.Name),
+		Freq:        req.Freq,
+		Interval:    req.Interval,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		Amount:      req.Amount,
+		Currency:    currency,
+		CategoryID:  categoryID,
+		Category:    category,
+		Description: req.Description,
+		AccountID:   req.AccountID,
+		Active:      true,
+	}
+	if !validateRecurringRule(c, r) || !validateRecurringStart(c, r.StartDate) {
+		return
+	}
+	if err := h.db.CreateRecurringRule(r); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpCreateRecurring, "recurring", strconv.FormatInt(r.ID, 10),
+		r.Name+" "+r.FormatRRule(), c.ClientIP(), c.GetHeader("User-Agent"))
+	r, _ = h.db.GetRecurringRule(r.LedgerID, r.ID)
+	c.JSON(http.StatusCreated, r)
+}
+
+// UpdateRecurringRule 更新周期记账规则。已生成的记录不受影响，之后的周期按新规则生成；
+// 暂停后恢复时不补记暂停期间的周期
+func (h *RecurringHandler) UpdateRecurringRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdateRecurringRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	r, err := h.db.GetRecurringRule(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if r == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "周期记账规则不存在"})
+		return
+	}
+	if req.Name != nil {
+		r.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Freq != nil {
+		r.Freq = *req.Freq
+	}
+	if req.Interval != nil {
+		r.Interval = *req.Interval
+	}
+	oldStart := r.StartDate
+	if req.StartDate != nil {
+		r.StartDate = *req.StartDate
+	}
+	if req.EndDate != nil {
+		r.EndDate = *req.EndDate
+	}
+	if req.Amount != nil {
+		r.Amount = *req.Amount
+	}
+	if req.Description != nil {
+		r.Description = *req.Description
+	}
+	if req.AccountID != nil || req.Currency != nil {
+		if req.AccountID != nil {
+			r.AccountID = req.AccountID
+			if *req.AccountID == 0 {
+				r.AccountID = nil
+			}
+		}
+		currency := ""
+		if req.Currency != nil {
+			currency = *req.Currency
+		}
+		if !checkAccount(c, h.db, r.AccountID, &currency, r.Currency) {
+			return
+		}
+		r.Currency = currency
+	}
+	if req.CategoryID != nil || req.Category != nil {
+		name := ""
+		if req.Category != nil {
+			name = *req.Category
+		}
+		categoryID, category, ok := resolveCategory(c, h.db, req.CategoryID, name, r.Amount)
+		if !ok {
+			return
+		}
+		r.CategoryID, r.Category = categoryID, category
+	}
+	skipBefore := ""
+	if req.Active != nil {
+		if *req.Active && !r.Active {
+			skipBefore = time.Now().Format("2006-01-02")
+		}
+		r.Active = *req.Active
+	}
+	if !validateRecurringRule(c, r) {
+		return
+	}
+	if r.StartDate != oldStart && !validateRecurringStart(c, r.StartDate) {
+		return
+	}
+	if err := h.db.UpdateRecurringRule(ledgerID, r, skipBefore); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "周期记账规则不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateRecurring, "recurring", strconv.FormatInt(id, 10),
+		r.Name+" "+r.FormatRRule(), c.ClientIP(), c.GetHeader("User-Agent"))
+	r, _ = h.db.GetRecurringRule(ledgerID, id)
+	c.JSON(http.StatusOK, r)
+}
+
+// DeleteRecurringRule 删除周期记账规则，已生成的记录保留
+func (h *RecurringHandler) DeleteRecurringRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.db.DeleteRecurringRule(middleware.GetLedgerID(c), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "周期记账规则不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteRecurring, "recurring", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// validateRecurringRule 校验名称、频率、间隔、金额与起止日期，失败时已写入响应
+func validateRecurringRule(c *gin.Context, r *models.RecurringRule) bool {
+	if r.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "名称不能为空"})
+		return false
+	}
+	if !models.ValidFreq(r.Freq) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "freq 须为 daily、weekly、monthly 或 yearly"})
+		return false
+	}
+	if r.Interval < 1 || r.Interval > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval 须在 1 到 366 之间"})
+		return false
+	}
+	if r.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "金额不能为 0"})
+		return false
+	}
+	if _, err := time.Parse("2006-01-02", r.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date 须为 YYYY-MM-DD"})
+		return false
+	}
+	if r.EndDate != "" {
+		if _, err := time.Parse("2006-01-02", r.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date 须为 YYYY-MM-DD"})
+			return false
+		}
+		if r.EndDate < r.StartDate {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date 不能早于 start_date"})
+			return false
+		}
+	}
+	return true
+}
+
+// validateRecurringStart 新设置的 start_date 不得早于 recurringMaxBackfill 之前，避免一次补记大量历史记录
+func validateRecurringStart(c *gin.Context, start string) bool {
+	if start < time.Now().AddDate(0, 0, -recurringMaxBackfill).Format("2006-01-02") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("start_date 不能早于 %d 天前", recurringMaxBackfill)})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordHistory 记录的修订历史（含回收站中的记录），按时间倒序，每个版本带字段级差异 GET /api/records/:id/history
+func (h *RecordHandler) RecordHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	ok, err := h.db.RecordExists(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	list, err := h.db.ListRevisions(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// RevertRecord 将记录回滚到指定版本修改后的状态 POST /api/records/:id/revert
+// 回滚本身作为一个新版本写入历史；已删除的记录须先从回收站恢复
+func (h *RecordHandler) RevertRecord(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.RevertRecordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	rev, err := h.db.GetRevision(ledgerID, id, req.RevisionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return
+	}
+	if rev.After == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该版本为删除操作，请通过回收站恢复"})
+		return
+	}
+	op, err := h.prepareUpdate(ledgerID, id, h.revertPatch(ledgerID, rev.After), true)
+	if err != nil {
+		writeRecordError(c, err)
+		return
+	}
+	uid := middleware.GetUserID(c)
+	applied, err := h.db.Revert(ledgerID, id, rev.ID, uid, ifMatchVersion(c), op.Patch)
+	if err != nil {
+		h.writeUpdateError(c, ledgerID, id, err)
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpRevertRecord, "record", strconv.FormatInt(id, 10),
+		"回滚到版本 "+strconv.FormatInt(rev.ID, 10)+" "+changedFields(applied), c.ClientIP(), c.GetHeader("User-Agent"))
+	if applied != nil {
+		applied, _ = h.db.GetRevision(ledgerID, id, applied.ID)
+	}
+	r, _ := h.db.GetByID(ledgerID, id)
+	setETag(c, r)
+	c.JSON(http.StatusOK, gin.H{"record": r, "revision": applied})
+}
+
+// revertPatch 由版本快照构造整体更新请求（含拆分行）。快照中的分类已被删除时按名称查找或重建
+func (h *RecordHandler) revertPatch(ledgerID int64, s *models.RecordSnapshot) *models.UpdateRecordRequest {
+	tags := s.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	accountID := s.AccountID
+	if accountID == nil {
+		accountID = new(int64)
+	}
+	req := &models.UpdateRecordRequest{
+		Date:        &s.Date,
+		Amount:      &s.Amount,
+		Currency:    &s.Currency,
+		Description: &s.Description,
+		Payee:       &s.Payee,
+		AccountID:   accountID,
+		Tags:        &tags,
+	}
+	switch {
+	case s.CategoryID != nil && *s.CategoryID != 0:
+		if cat, err := h.db.GetCategory(ledgerID, *s.CategoryID); err == nil && cat != nil {
+			req.CategoryID = s.CategoryID
+			break
+		}
+		req.Category = &s.Category
+	case s.Category != "":
+		req.Category = &s.Category
+	default:
+		req.CategoryID = new(int64)
+	}
+	splits := make([]*models.Split, len(s.Splits))
+	for i, sp := range s.Splits {
+		cp := *sp
+		if cp.CategoryID != nil && *cp.CategoryID != 0 {
+			if cat, err := h.db.GetCategory(ledgerID, *cp.CategoryID); err != nil || cat == nil {
+				cp.CategoryID = nil
+			}
+		}
+		splits[i] = &cp
+	}
+	req.Splits = &splits
+	return req
+}
+
+// changedFields 修订中有变化的字段名，用于操作日志
+func changedFields(rev *models.RecordRevision) string {
+	if rev == nil {
+		return "无变化"
+	}
+	fields := make([]string, len(rev.Changes))
+	for i, ch := range rev.Changes {
+		fields[i] = ch.Field
+	}
+	return strings.Join(fields, ",")
+}
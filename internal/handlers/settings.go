@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SettingsHandler struct {
+	db *database.DB
+}
+
+func NewSettingsHandler(db *database.DB) *SettingsHandler {
+	return &SettingsHandler{db: db}
+}
+
+// GetSettings 当前用户偏好设置 GET /api/settings
+func (h *SettingsHandler) GetSettings(c *gin.Context) {
+	st, err := h.db.GetUserSettings(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// UpdateSettings 修改偏好设置 PUT /api/settings
+func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
+	var req models.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := middleware.GetUserID(c)
+	st, err := h.db.GetUserSettings(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.BaseCurrency != nil {
+		cur, ok := models.NormalizeCurrency(*req.BaseCurrency)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base_currency 无效"})
+			return
+		}
+		st.BaseCurrency = cur
+	}
+	if req.WeekStart != nil {
+		if !models.ValidWeekStart(*req.WeekStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "week_start 须为 iso、monday、sunday 或 saturday"})
+			return
+		}
+		st.WeekStart = *req.WeekStart
+	}
+	if req.MonthStartDay != nil {
+		if *req.MonthStartDay < 1 || *req.MonthStartDay > models.MonthStartDayMax {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month_start_day 须在 1 到 28 之间"})
+			return
+		}
+		st.MonthStartDay = *req.MonthStartDay
+	}
+	if req.YearStartMonth != nil {
+		if *req.YearStartMonth < 1 || *req.YearStartMonth > 12 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year_start_month 须在 1 到 12 之间"})
+			return
+		}
+		st.YearStartMonth = *req.YearStartMonth
+	}
+	if err := h.db.UpdateUserSettings(uid, st); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateSettings, "user", "", fmt.Sprintf("本位币:%s 周起始:%s 月起始日:%d 财年起始月:%d", st.BaseCurrency, st.WeekStart, st.MonthStartDay, st.YearStartMonth), c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, st)
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +19,82 @@ func NewSummaryHandler(db *database.DB) *SummaryHandler {
 	return &SummaryHandler{db: db}
 }
 
+// settings 当前用户的偏好设置（本位币等），失败时已写入响应
+func (h *SummaryHandler) settings(c *gin.Context) (*models.UserSettings, bool) {
+	return userSettings(c, h.db)
+}
+
+// userSettings 读取当前用户的偏好设置，失败时已写入响应
+func userSettings(c *gin.Context, db *database.DB) (*models.UserSettings, bool) {
+	st, err := db.GetUserSettings(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return st, true
+}
+
+// Summary 按周期分项的汇总 GET /api/summary?group_by=week&start_date=2024-01-01&end_date=2024-03-31&week_start=iso
+// group_by 为 day/week/month/quarter/year，缺省为 day；week_start 缺省取用户设置
+func (h *SummaryHandler) Summary(c *gin.Context) {
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+	if startDate == "" || endDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 start_date 或 end_date"})
+		return
+	}
+	for _, d := range []string{startDate, endDate} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式须为 YYYY-MM-DD"})
+			return
+		}
+	}
+	if startDate > endDate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date 不能大于 end_date"})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", models.GroupByDay)
+	if !models.ValidGroupBy(groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by 须为 day、week、month、quarter 或 year"})
+		return
+	}
+	st, ok := h.settings(c)
+	if !ok {
+		return
+	}
+	weekStart := c.DefaultQuery("week_start", st.WeekStart)
+	if !models.ValidWeekStart(weekStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "week_start 须为 iso、monday、sunday 或 saturday"})
+		return
+	}
+	cal := st.Calendar()
+	cal.WeekStart = weekStart
+	q := models.SummaryQuery{StartDate: startDate, EndDate: endDate, GroupBy: groupBy, Calendar: cal}
+	s, err := h.db.Summary(middleware.GetLedgerID(c), q, st.BaseCurrency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{
+		"start_date":  startDate,
+		"end_date":    endDate,
+		"group_by":    groupBy,
+		"currency":    s.Currency,
+		"income":      s.Income,
+		"expense":     s.Expense,
+		"balance":     s.Balance,
+		"count":       s.Count,
+		"unconverted": s.Unconverted,
+		"breakdown":   s.Breakdown,
+		"by_account":  s.ByAccount,
+		"by_currency": s.ByCurrency,
+	}
+	if groupBy == models.GroupByWeek {
+		resp["week_start"] = weekStart
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // DailySummary 每日汇总 GET /api/summary/daily?date=2024-02-06
 func (h *SummaryHandler) DailySummary(c *gin.Context) {
 	date := c.Query("date")
@@ -23,22 +102,30 @@ func (h *SummaryHandler) DailySummary(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 date 参数"})
 		return
 	}
-	s, err := h.db.DailySummary(date)
+	st, ok := h.settings(c)
+	if !ok {
+		return
+	}
+	s, err := h.db.DailySummary(middleware.GetLedgerID(c), date, st.BaseCurrency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"date":   date,
-		"income": s.Income,
-		"expense": s.Expense,
-		"balance": s.Balance,
-		"count":   s.Count,
-		"records": s.Records,
+		"date":        date,
+		"currency":    s.Currency,
+		"income":      s.Income,
+		"expense":     s.Expense,
+		"balance":     s.Balance,
+		"count":       s.Count,
+		"unconverted": s.Unconverted,
+		"by_currency": s.ByCurrency,
+		"records":     s.Records,
 	})
 }
 
 // MonthlySummary 每月汇总 GET /api/summary/monthly?year=2024&month=2
+// 设置了每月起始日时统计当月起始日至次月起始日前一天，start_date/end_date 为实际范围
 func (h *SummaryHandler) MonthlySummary(c *gin.Context) {
 	year, _ := strconv.Atoi(c.Query("year"))
 	month, _ := strconv.Atoi(c.Query("month"))
@@ -46,60 +133,151 @@ func (h *SummaryHandler) MonthlySummary(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "year 和 month 参数无效"})
 		return
 	}
-	s, err := h.db.MonthlySummary(year, month)
+	st, ok := h.settings(c)
+	if !ok {
+		return
+	}
+	s, err := h.db.MonthlySummary(middleware.GetLedgerID(c), year, month, st.Calendar(), st.BaseCurrency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"year":     year,
-		"month":    month,
-		"income":   s.Income,
-		"expense":  s.Expense,
-		"balance":  s.Balance,
-		"count":    s.Count,
-		"breakdown": s.Breakdown,
+		"year":        year,
+		"month":       month,
+		"start_date":  s.StartDate,
+		"end_date":    s.EndDate,
+		"currency":    s.Currency,
+		"income":      s.Income,
+		"expense":     s.Expense,
+		"balance":     s.Balance,
+		"count":       s.Count,
+		"unconverted": s.Unconverted,
+		"breakdown":   s.Breakdown,
+		"by_account":  s.ByAccount,
+		"by_currency": s.ByCurrency,
 	})
 }
 
 // YearlySummary 每年汇总 GET /api/summary/yearly?year=2024
+// 设置了财年起始月或每月起始日时 year 为财年（以起始日所在年份命名），按财年内的月份分项
 func (h *SummaryHandler) YearlySummary(c *gin.Context) {
 	year, _ := strconv.Atoi(c.Query("year"))
 	if year < 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "year 参数无效"})
 		return
 	}
-	s, err := h.db.YearlySummary(year)
+	st, ok := h.settings(c)
+	if !ok {
+		return
+	}
+	s, err := h.db.YearlySummary(middleware.GetLedgerID(c), year, st.Calendar(), st.BaseCurrency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"year":      year,
-		"income":    s.Income,
-		"expense":   s.Expense,
-		"balance":   s.Balance,
-		"count":     s.Count,
-		"breakdown": s.Breakdown,
+		"year":        year,
+		"start_date":  s.StartDate,
+		"end_date":    s.EndDate,
+		"currency":    s.Currency,
+		"income":      s.Income,
+		"expense":     s.Expense,
+		"balance":     s.Balance,
+		"count":       s.Count,
+		"unconverted": s.Unconverted,
+		"breakdown":   s.Breakdown,
+		"by_account":  s.ByAccount,
+		"by_currency": s.ByCurrency,
 	})
 }
 
-// Report 报表 GET /api/report?start_date=2024-01-01&end_date=2024-12-31
+// Report 报表 GET /api/report?start_date=2024-01-01&end_date=2024-12-31&category_id=
+// 也可用 year（及 month）代替日期范围，按用户设置的财年与每月起始日取范围；传 category_id 时下钻到该分类，按其子分类统计。
+// compare=previous_period|same_period_last_year 时附带对比区间的合计与变化，分类增幅超过 threshold（百分比，缺省 20）时标记
 func (h *SummaryHandler) Report(c *gin.Context) {
+	st, ok := h.settings(c)
+	if !ok {
+		return
+	}
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
+	if startDate == "" && endDate == "" && c.Query("year") != "" {
+		var ok bool
+		if startDate, endDate, ok = periodRange(c, st.Calendar()); !ok {
+			return
+		}
+	}
 	if startDate == "" || endDate == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 start_date 或 end_date"})
 		return
 	}
+	for _, d := range []string{startDate, endDate} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式须为 YYYY-MM-DD"})
+			return
+		}
+	}
 	if startDate > endDate {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date 不能大于 end_date"})
 		return
 	}
-	r, err := h.db.Report(startDate, endDate)
+	ledgerID := middleware.GetLedgerID(c)
+	var categoryID int64
+	if v := c.Query("category_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category_id 无效"})
+			return
+		}
+		cat, err := h.db.GetCategory(ledgerID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cat == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分类不存在"})
+			return
+		}
+		categoryID = id
+	}
+	q := models.ReportQuery{StartDate: startDate, EndDate: endDate, CategoryID: categoryID, Calendar: st.Calendar()}
+	if q.Compare = c.Query("compare"); q.Compare != "" && !models.ValidCompare(q.Compare) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "compare 须为 previous_period 或 same_period_last_year"})
+		return
+	}
+	q.Threshold = models.CompareThresholdDefault
+	if v := c.Query("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold 须为非负数"})
+			return
+		}
+		q.Threshold = t
+	}
+	r, err := h.db.Report(ledgerID, q, st.BaseCurrency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, r)
 }
+
+// periodRange 由 year（财年）与可选的 month 按 cal 取日期范围，参数无效时已写入响应
+func periodRange(c *gin.Context, cal models.Calendar) (string, string, bool) {
+	year, _ := strconv.Atoi(c.Query("year"))
+	if year < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year 参数无效"})
+		return "", "", false
+	}
+	start, end := cal.YearRange(year)
+	if v := c.Query("month"); v != "" {
+		month, _ := strconv.Atoi(v)
+		if month < 1 || month > 12 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month 参数无效"})
+			return "", "", false
+		}
+		start, end = cal.MonthRange(year, month)
+	}
+	return start.Format("2006-01-02"), end.Format("2006-01-02"), true
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	db *database.DB
+}
+
+func NewTransferHandler(db *database.DB) *TransferHandler {
+	return &TransferHandler{db: db}
+}
+
+// ListTransfers 转账列表 GET /api/transfers?start_date=&end_date=
+func (h *TransferHandler) ListTransfers(c *gin.Context) {
+	list, err := h.db.ListTransfers(middleware.GetLedgerID(c), c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// GetTransfer 获取单笔转账
+func (h *TransferHandler) GetTransfer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	t, err := h.db.GetTransfer(middleware.GetLedgerID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return
+	}
+	setTransferETag(c, t)
+	c.JSON(http.StatusOK, t)
+}
+
+// CreateTransfer 创建转账（生成转出、转入两条分录）
+func (h *TransferHandler) CreateTransfer(c *gin.Context) {
+	var req models.CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t := &models.Transfer{
+		LedgerID:      middleware.GetLedgerID(c),
+		UserID:        middleware.GetUserID(c),
+		Date:          req.Date,
+		Amount:        req.Amount,
+		ToAmount:      req.ToAmount,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Description:   req.Description,
+	}
+	if !h.validate(c, t) {
+		return
+	}
+	if err := h.db.CreateTransfer(t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(t.UserID, username.(string), database.OpCreateTransfer, "transfer", strconv.FormatInt(t.ID, 10),
+		req.Date+" "+req.Amount.String(), c.ClientIP(), c.GetHeader("User-Agent"))
+	t, _ = h.db.GetTransfer(t.LedgerID, t.ID)
+	setTransferETag(c, t)
+	c.JSON(http.StatusCreated, t)
+}
+
+// UpdateTransfer 更新转账，两条分录在同一事务中同步修改；带 If-Match 且版本已过期时返回 412
+func (h *TransferHandler) UpdateTransfer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req models.UpdateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	cur, err := h.db.GetTransfer(ledgerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cur == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return
+	}
+	// 以合并后的结果做校验；金额或账户变化而未给出 to_amount 时，跨币种转账须重新提供
+	next := *cur
+	if req.Date != nil {
+		next.Date = *req.Date
+	}
+	if req.Description != nil {
+		next.Description = *req.Description
+	}
+	if req.Amount != nil || req.FromAccountID != nil || req.ToAccountID != nil {
+		next.ToAmount = 0
+	}
+	if req.Amount != nil {
+		next.Amount = *req.Amount
+	}
+	if req.ToAmount != nil {
+		next.ToAmount = *req.ToAmount
+	}
+	if req.FromAccountID != nil {
+		next.FromAccountID = *req.FromAccountID
+	}
+	if req.ToAccountID != nil {
+		next.ToAccountID = *req.ToAccountID
+	}
+	if !h.validate(c, &next) {
+		return
+	}
+	if err := h.db.UpdateTransfer(ledgerID, middleware.GetUserID(c), ifMatchVersion(c), &next); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		case errors.Is(err, database.ErrVersionConflict):
+			// 返回服务端当前转账（附 ETag），与记录的版本冲突处理一致
+			cur, _ := h.db.GetTransfer(ledgerID, id)
+			setTransferETag(c, cur)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error(), "current": cur})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpUpdateTransfer, "transfer", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	t, _ := h.db.GetTransfer(ledgerID, id)
+	setTransferETag(c, t)
+	c.JSON(http.StatusOK, t)
+}
+
+// setTransferETag 以转账版本号设置 ETag 响应头
+func setTransferETag(c *gin.Context, t *models.Transfer) {
+	if t != nil {
+		c.Header("ETag", `"`+strconv.FormatInt(t.Version, 10)+`"`)
+	}
+}
+
+// DeleteTransfer 删除转账及两条分录；带 If-Match 且版本已过期时返回 412
+func (h *TransferHandler) DeleteTransfer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	uid := middleware.GetUserID(c)
+	if err := h.db.DeleteTransfer(ledgerID, id, uid, ifMatchVersion(c)); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		case errors.Is(err, database.ErrVersionConflict):
+			cur, _ := h.db.GetTransfer(ledgerID, id)
+			setTransferETag(c, cur)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error(), "current": cur})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpDeleteTransfer, "transfer", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// validate 校验金额与转出/转入账户，并按账户确定两侧币种与转入金额，失败时已写入响应
+func (h *TransferHandler) validate(c *gin.Context, t *models.Transfer) bool {
+	if t.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "转账金额须大于 0"})
+		return false
+	}
+	if t.FromAccountID == t.ToAccountID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "转出与转入账户不能相同"})
+		return false
+	}
+	currencies := [2]*string{&t.FromCurrency, &t.ToCurrency}
+	for i, id := range []int64{t.FromAccountID, t.ToAccountID} {
+		a, err := h.db.GetAccount(t.LedgerID, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if a == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "账户不存在"})
+			return false
+		}
+		if a.Archived {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "账户已归档"})
+			return false
+		}
+		*currencies[i] = a.Currency
+	}
+	if t.FromCurrency == t.ToCurrency {
+		t.ToAmount = t.Amount
+		return true
+	}
+	if t.ToAmount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "跨币种转账须提供 to_amount"})
+		return false
+	}
+	return true
+}
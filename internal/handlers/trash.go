@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/middleware"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash 回收站中的记录，按删除时间倒序 GET /api/records/trash?page=1&page_size=20
+func (h *RecordHandler) ListTrash(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	list, total, err := h.db.ListTrash(middleware.GetLedgerID(c), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "total": total, "page": page, "size": pageSize})
+}
+
+// RestoreRecord 从回收站恢复记录 POST /api/records/trash/:id/restore
+// 转账分录会连同整笔转账一起恢复
+func (h *RecordHandler) RestoreRecord(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ledgerID := middleware.GetLedgerID(c)
+	uid := middleware.GetUserID(c)
+	if err := h.db.RestoreRecord(ledgerID, id, uid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "回收站中没有该记录"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpRestoreRecord, "record", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	r, _ := h.db.GetByID(ledgerID, id)
+	c.JSON(http.StatusOK, r)
+}
+
+// PurgeRecord 彻底删除回收站中的记录，不可恢复 DELETE /api/records/trash/:id
+func (h *RecordHandler) PurgeRecord(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	keys, err := h.db.PurgeRecord(middleware.GetLedgerID(c), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "回收站中没有该记录"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.deleteFiles(keys)
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpPurgeRecord, "record", strconv.FormatInt(id, 10), "", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"message": "purged"})
+}
+
+// EmptyTrash 清空回收站 DELETE /api/records/trash
+func (h *RecordHandler) EmptyTrash(c *gin.Context) {
+	ledgerID := middleware.GetLedgerID(c)
+	n, keys, err := h.db.EmptyTrash(ledgerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.deleteFiles(keys)
+	uid := middleware.GetUserID(c)
+	username, _ := c.Get("username")
+	_ = h.db.LogOperation(uid, username.(string), database.OpEmptyTrash, "ledger", strconv.FormatInt(ledgerID, 10),
+		"彻底删除 "+strconv.FormatInt(n, 10)+" 条记录", c.ClientIP(), c.GetHeader("User-Agent"))
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
@@ -77,7 +77,7 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "已更新"})
 }
 
-// DeleteUser 删除用户（管理员）
+// DeleteUser 删除用户（管理员），仅有该用户一名成员的账本及其附件一并删除
 func (h *AuthHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -94,10 +94,19 @@ func (h *AuthHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "不能删除其他管理员"})
 		return
 	}
-	if err := h.db.DeleteUser(id); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+	keys, err := h.db.DeleteUser(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		case errors.Is(err, database.ErrLastOwner):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "该用户是共享账本的唯一所有者，请先转移所有权"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "操作失败"})
+		}
 		return
 	}
+	deleteFiles(h.files, keys)
 	operatorName, _ := c.Get("username")
 	_ = h.db.LogOperation(curUserID, operatorName.(string), database.OpDeleteUser, "user", strconv.FormatInt(id, 10), "删除用户:"+u.Username, c.ClientIP(), c.GetHeader("User-Agent"))
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
@@ -141,18 +150,36 @@ func (h *AuthHandler) AdminChangeUserPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "密码已修改"})
 }
 
-// ListOperationLogs 操作日志列表（管理员）
+// ListOperationLogs 操作日志列表（管理员），带 cursor 参数时为游标分页
 func (h *AuthHandler) ListOperationLogs(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	var userID *int64
+	q := &database.OperationLogQuery{Action: c.Query("action")}
+	q.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	q.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
 	if uidStr := c.Query("user_id"); uidStr != "" {
 		if uid, err := strconv.ParseInt(uidStr, 10, 64); err == nil {
-			userID = &uid
+			q.UserID = &uid
+		}
+	}
+	var cursor string
+	cursor, q.CursorMode = c.GetQuery("cursor")
+	if cursor != "" {
+		var cur models.LogCursor
+		if err := models.DecodeCursor(cursor, &cur); err != nil || cur.ID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrInvalidCursor.Error()})
+			return
+		}
+		q.BeforeID = cur.ID
+	}
+	q.WithTotal = !q.CursorMode
+	if s := c.Query("with_total"); s != "" {
+		withTotal, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "with_total 须为 true 或 false"})
+			return
 		}
+		q.WithTotal = withTotal
 	}
-	action := c.Query("action")
-	list, total, err := h.db.ListOperationLogs(page, pageSize, userID, action)
+	list, total, next, err := h.db.ListOperationLogs(q)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -161,12 +188,32 @@ func (h *AuthHandler) ListOperationLogs(c *gin.Context) {
 		database.OpLogin: "登录", database.OpCreateRecord: "创建记账", database.OpUpdateRecord: "更新记账",
 		database.OpDeleteRecord: "删除记账", database.OpAddUser: "添加用户", database.OpUpdateUser: "更新用户",
 		database.OpDeleteUser: "删除用户", database.OpChangePwd: "修改密码", database.OpTOTPEnable: "启用TOTP",
-		database.OpTOTPDisable: "关闭TOTP",
+		database.OpTOTPDisable: "关闭TOTP", database.OpCreateLedger: "创建账本", database.OpInviteMember: "邀请成员",
+		database.OpUpdateMember: "修改成员角色", database.OpRemoveMember: "移除成员", database.OpCreateAccount: "创建账户",
+		database.OpUpdateAccount: "更新账户", database.OpDeleteAccount: "删除账户", database.OpCreateTransfer: "创建转账",
+		database.OpUpdateTransfer: "更新转账", database.OpDeleteTransfer: "删除转账", database.OpUpdateSettings: "修改设置",
+		database.OpSaveRates: "保存汇率", database.OpDeleteRate: "删除汇率", database.OpCreateCategory: "创建分类",
+		database.OpUpdateCategory: "更新分类", database.OpDeleteCategory: "删除分类", database.OpDeleteTag: "删除标签",
+		database.OpCreateBudget: "创建预算", database.OpUpdateBudget: "更新预算", database.OpDeleteBudget: "删除预算",
+		database.OpCreateRecurring: "创建周期记账", database.OpUpdateRecurring: "更新周期记账", database.OpDeleteRecurring: "删除周期记账",
+		database.OpRecurringRecord: "周期记账入账", database.OpRestoreRecord: "恢复记账", database.OpPurgeRecord: "彻底删除记账",
+		database.OpEmptyTrash: "清空回收站", database.OpRevertRecord: "回滚记账",
+		database.OpUploadAttachment: "上传附件", database.OpDeleteAttachment: "删除附件",
+		database.OpCreatePayee: "创建交易对方", database.OpUpdatePayee: "更新交易对方", database.OpDeletePayee: "删除交易对方",
 	}
 	for _, l := range list {
 		if name, ok := actionNames[l.Action]; ok {
 			l.Action = name
 		}
 	}
-	c.JSON(http.StatusOK, gin.H{"data": list, "total": total, "page": page, "page_size": pageSize})
+	resp := gin.H{"data": list, "page_size": q.PageSize}
+	if q.CursorMode {
+		resp["next_cursor"] = next
+	} else {
+		resp["page"] = q.Page
+	}
+	if q.WithTotal {
+		resp["total"] = total
+	}
+	c.JSON(http.StatusOK, resp)
 }
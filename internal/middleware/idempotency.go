@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"account-service/internal/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyStore 幂等键的存取（由 database.DB 实现）
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(userID int64, key, requestHash string, expiresAt time.Time) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(userID int64, key string, status int, headers map[string]string, body []byte) error
+	ReleaseIdempotencyKey(userID int64, key string) error
+}
+
+// replayHeaders 随响应一起保存并在重放时返回的响应头
+var replayHeaders = []string{"Content-Type", "ETag"}
+
+// Idempotency 按 Idempotency-Key 请求头对写请求去重，需在 Auth 之后使用；未带该请求头时不做处理。
+// 同一用户的同一键在 ttl 内重复提交时直接重放首次的响应（带 Idempotent-Replayed: true）；
+// 请求内容（方法、路由、账本与请求体）不同时返回 422；首个请求仍在处理时返回 409。
+// 首个请求返回 5xx 或处理中 panic 时释放该键，允许以同一键重试
+func Idempotency(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key 不能超过 255 个字符"})
+			c.Abort()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h := sha256.New()
+		for _, part := range []string{c.Request.Method, c.FullPath(), c.Query("ledger_id")} {
+			h.Write([]byte(part + "\n"))
+		}
+		h.Write(body)
+		hash := hex.EncodeToString(h.Sum(nil))
+
+		userID := GetUserID(c)
+		prev, err := store.ReserveIdempotencyKey(userID, key, hash, time.Now().Add(ttl))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if prev != nil {
+			switch {
+			case prev.RequestHash != hash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key 已用于内容不同的请求"})
+			case prev.Status == 0:
+				c.JSON(http.StatusConflict, gin.H{"error": "相同 Idempotency-Key 的请求正在处理，请稍后重试"})
+			default:
+				for k, v := range prev.Headers {
+					c.Header(k, v)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(prev.Status, prev.Headers["Content-Type"], prev.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			// 处理中 panic 时释放幂等键后继续上抛，否则该键一直处于处理中，客户端无法重试
+			if r := recover(); r != nil {
+				if err := store.ReleaseIdempotencyKey(userID, key); err != nil {
+					log.Printf("释放幂等键 %q: %v", key, err)
+				}
+				panic(r)
+			}
+		}()
+		c.Next()
+		status := w.Status()
+		if status >= http.StatusInternalServerError {
+			err = store.ReleaseIdempotencyKey(userID, key)
+		} else {
+			headers := map[string]string{}
+			for _, k := range replayHeaders {
+				if v := w.Header().Get(k); v != "" {
+					headers[k] = v
+				}
+			}
+			err = store.CompleteIdempotencyKey(userID, key, status, headers, w.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("保存幂等键 %q: %v", key, err)
+		}
+	}
+}
+
+// captureWriter 在写出响应的同时保留响应体
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"account-service/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newIdempotencyRouter 以临时数据库为存储的路由，calls 统计处理函数实际执行的次数
+func newIdempotencyRouter(t *testing.T, handler gin.HandlerFunc) (*gin.Engine, *int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	var calls int32
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	r.Use(func(c *gin.Context) { c.Set("user_id", int64(1)) })
+	r.POST("/records", Idempotency(db, time.Hour), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		handler(c)
+	})
+	return r, &calls
+}
+
+func postIdempotent(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var id int32
+	r, calls := newIdempotencyRouter(t, func(c *gin.Context) {
+		c.Header("ETag", `"1"`)
+		c.JSON(http.StatusCreated, gin.H{"id": atomic.AddInt32(&id, 1)})
+	})
+	first := postIdempotent(r, "k1", `{"amount":1}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first = %d %q", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+	second := postIdempotent(r, "k1", `{"amount":1}`)
+	if second.Code != http.StatusCreated || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay = %d, Idempotent-Replayed %q", second.Code, second.Header().Get("Idempotent-Replayed"))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("replay body = %s, want %s", second.Body, first.Body)
+	}
+	if got := second.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("replay ETag = %q", got)
+	}
+	if got := second.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+		t.Errorf("replay Content-Type = %q, want %q", got, first.Header().Get("Content-Type"))
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+	// 未带请求头或换用新键时照常处理
+	postIdempotent(r, "", `{"amount":1}`)
+	postIdempotent(r, "k2", `{"amount":1}`)
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("handler ran %d times, want 3", n)
+	}
+}
+
+func TestIdempotencyDifferentBody(t *testing.T) {
+	r, calls := newIdempotencyRouter(t, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+	postIdempotent(r, "k1", `{"amount":1}`)
+	if w := postIdempotent(r, "k1", `{"amount":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body = %d, want 422", w.Code)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r, _ := newIdempotencyRouter(t, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(r, "k1", `{}`) }()
+	<-started
+	if w := postIdempotent(r, "k1", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("in-flight = %d, want 409", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Errorf("first = %d, want 201", w.Code)
+	}
+	if w := postIdempotent(r, "k1", `{}`); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("after completion = %d, Idempotent-Replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+// TestIdempotencyRelease 首次返回 5xx 或 panic 时释放键，以同一键重试会再次执行
+func TestIdempotencyRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		fail gin.HandlerFunc
+	}{
+		{"5xx", func(c *gin.Context) { c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"}) }},
+		{"panic", func(c *gin.Context) { panic("boom") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failed int32
+			r, calls := newIdempotencyRouter(t, func(c *gin.Context) {
+				if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+					tt.fail(c)
+					return
+				}
+				c.JSON(http.StatusCreated, gin.H{})
+			})
+			if w := postIdempotent(r, "k1", `{}`); w.Code != http.StatusInternalServerError {
+				t.Fatalf("first = %d, want 500", w.Code)
+			}
+			w := postIdempotent(r, "k1", `{}`)
+			if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+				t.Errorf("retry = %d, Idempotent-Replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+			}
+			if n := atomic.LoadInt32(calls); n != 2 {
+				t.Errorf("handler ran %d times, want 2", n)
+			}
+		})
+	}
+}
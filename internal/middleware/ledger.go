@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"account-service/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerStore 账本成员关系查询（由 database.DB 实现）
+type LedgerStore interface {
+	DefaultLedgerID(userID int64) (int64, error)
+	LedgerRole(ledgerID, userID int64) (string, error)
+}
+
+// Ledger 解析当前请求的账本并校验成员角色，需在 Auth 之后使用。
+// 账本 ID 依次取自路径参数 :ledger_id、查询参数 ledger_id，均未提供时使用用户的默认账本。
+func Ledger(store LedgerStore, minRole string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		raw := c.Param("ledger_id")
+		if raw == "" {
+			raw = c.Query("ledger_id")
+		}
+		var ledgerID int64
+		if raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id 无效"})
+				c.Abort()
+				return
+			}
+			ledgerID = id
+		} else {
+			id, err := store.DefaultLedgerID(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			ledgerID = id
+		}
+		role, err := store.LedgerRole(ledgerID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "账本不存在或无权访问"})
+			c.Abort()
+			return
+		}
+		if models.LedgerRoleRank(role) < models.LedgerRoleRank(minRole) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "账本权限不足"})
+			c.Abort()
+			return
+		}
+		c.Set("ledger_id", ledgerID)
+		c.Set("ledger_role", role)
+		c.Next()
+	}
+}
+
+func GetLedgerID(c *gin.Context) int64 {
+	v, _ := c.Get("ledger_id")
+	if id, ok := v.(int64); ok {
+		return id
+	}
+	return 0
+}
+
+func GetLedgerRole(c *gin.Context) string {
+	v, _ := c.Get("ledger_role")
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
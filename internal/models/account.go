@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// 账户类型
+const (
+	AccountTypeCash    = "cash"    // 现金
+	AccountTypeBank    = "bank"    // 银行卡
+	AccountTypeCredit  = "credit"  // 信用卡
+	AccountTypeEWallet = "ewallet" // 支付宝、微信等电子钱包
+	AccountTypeOther   = "other"
+)
+
+const DefaultCurrency = "CNY"
+
+func ValidAccountType(t string) bool {
+	switch t {
+	case AccountTypeCash, AccountTypeBank, AccountTypeCredit, AccountTypeEWallet, AccountTypeOther:
+		return true
+	}
+	return false
+}
+
+// Account 资金账户
+type Account struct {
+	ID             int64     `json:"id"`
+	LedgerID       int64     `json:"ledger_id"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	OpeningBalance Money     `json:"opening_balance"` // 期初余额
+	Currency       string    `json:"currency"`
+	Archived       bool      `json:"archived"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CreateAccountRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Type           string `json:"type"` // 缺省为 other
+	OpeningBalance Money  `json:"opening_balance"`
+	Currency       string `json:"currency"` // 缺省为 CNY
+}
+
+type UpdateAccountRequest struct {
+	Name           *string `json:"name"`
+	Type           *string `json:"type"`
+	OpeningBalance *Money  `json:"opening_balance"`
+	Currency       *string `json:"currency"`
+	Archived       *bool   `json:"archived"`
+}
+
+// AccountBalance 账户余额：Balance 为截至指定日期（含）的余额，CurrentBalance 为截至今日的余额
+type AccountBalance struct {
+	AccountID      int64  `json:"account_id"`
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Currency       string `json:"currency"`
+	Archived       bool   `json:"archived"`
+	OpeningBalance Money  `json:"opening_balance"`
+	Balance        Money  `json:"balance"`
+	CurrentBalance Money  `json:"current_balance"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AttachmentContentTypes 允许上传的附件类型（按文件内容识别）
+var AttachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// AttachmentMaxPerRecord 单条记录最多的附件数
+const AttachmentMaxPerRecord = 20
+
+// Attachment 记录的附件（票据照片、发票 PDF 等）
+type Attachment struct {
+	ID           int64     `json:"id"`
+	LedgerID     int64     `json:"ledger_id"`
+	RecordID     int64     `json:"record_id"`
+	UserID       int64     `json:"user_id"` // 上传者
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	StorageKey   string    `json:"-"`
+	ThumbKey     string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
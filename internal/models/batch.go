@@ -0,0 +1,36 @@
+package models
+
+// BatchMaxItems 单次批量操作涉及的记录数上限
+const BatchMaxItems = 1000
+
+// BatchOperation 批量操作中的一项：create 用 Record，update 用 ID 与 Patch，delete 用 ID
+type BatchOperation struct {
+	Op     string               `json:"op"`
+	ID     int64                `json:"id"`
+	Record *CreateRecordRequest `json:"record"`
+	Patch  *UpdateRecordRequest `json:"patch"`
+}
+
+// BatchRequest 批量操作请求，二选一：
+// Operations 逐项列出操作；或以 Filter 选出记录（不含转账分录），统一应用 Patch，或在 Delete 为 true 时删除
+type BatchRequest struct {
+	Operations []BatchOperation     `json:"operations"`
+	Filter     *QueryParams         `json:"filter"`
+	Patch      *UpdateRecordRequest `json:"patch"`
+	Delete     bool                 `json:"delete"`
+}
+
+// 批量操作项的结果状态
+const (
+	BatchStatusOK         = "ok"
+	BatchStatusError      = "error"
+	BatchStatusNotApplied = "not_applied" // 因其他项失败而未执行或已回滚
+)
+
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int64  `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
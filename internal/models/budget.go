@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// 预算周期
+const (
+	BudgetPeriodMonthly = "monthly"
+	BudgetPeriodYearly  = "yearly"
+)
+
+// Budget 预算：按月或按年的支出上限，CategoryID 为空表示账本总预算，否则含该分类及其子分类。
+// Rollover 为 true 时上一周期未用完的额度结转到下一周期
+type Budget struct {
+	ID         int64     `json:"id"`
+	LedgerID   int64     `json:"ledger_id"`
+	CategoryID *int64    `json:"category_id"`
+	Category   string    `json:"category"` // 分类名称，总预算为空
+	Period     string    `json:"period"`   // monthly 或 yearly
+	Amount     Money     `json:"amount"`   // 每周期额度
+	Currency   string    `json:"currency"` // 额度币种，支出按此币种换算
+	Rollover   bool      `json:"rollover"`
+	StartDate  string    `json:"start_date"` // 首个周期的起始日
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type CreateBudgetRequest struct {
+	CategoryID *int64 `json:"category_id"`
+	Period     string `json:"period" binding:"required"`
+	Amount     Money  `json:"amount" binding:"required"`
+	Currency   string `json:"currency"` // 缺省为用户本位币
+	Rollover   bool   `json:"rollover"`
+	StartDate  string `json:"start_date"` // 缺省为当前周期
+}
+
+type UpdateBudgetRequest struct {
+	Amount    *Money  `json:"amount"`
+	Rollover  *bool   `json:"rollover"`
+	StartDate *string `json:"start_date"`
+}
+
+// BudgetPeriodStatus 预算在某一周期的执行情况
+type BudgetPeriodStatus struct {
+	Period      string  `json:"period"` // 2024-03 或 2024
+	StartDate   string  `json:"start_date"`
+	EndDate     string  `json:"end_date"`
+	Budget      Money   `json:"budget"`       // 本周期额度
+	Carryover   Money   `json:"carryover"`    // 上期结转
+	Available   Money   `json:"available"`    // 额度 + 结转
+	Spent       Money   `json:"spent"`        // 已支出
+	Remaining   Money   `json:"remaining"`    // 可用 - 已支出，超支为负
+	PercentUsed float64 `json:"percent_used"` // 已用百分比
+	Over        bool    `json:"over"`         // 是否超支
+}
+
+// BudgetStatus 预算的当前周期及历史周期执行情况（Periods 按时间先后排列，末项为当前周期）
+type BudgetStatus struct {
+	Budget  *Budget               `json:"budget"`
+	Current *BudgetPeriodStatus   `json:"current"`
+	Periods []*BudgetPeriodStatus `json:"periods"`
+}
+
+func ValidBudgetPeriod(p string) bool {
+	return p == BudgetPeriodMonthly || p == BudgetPeriodYearly
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// 分类类型
+const (
+	CategoryTypeIncome  = "income"
+	CategoryTypeExpense = "expense"
+)
+
+// UncategorizedName 未关联分类的记录在报表中的名称
+const UncategorizedName = "未分类"
+
+// Category 账本内的收支分类，最多两级：ParentID 为空时为一级分类
+type Category struct {
+	ID        int64       `json:"id"`
+	LedgerID  int64       `json:"ledger_id"`
+	ParentID  *int64      `json:"parent_id"`
+	Name      string      `json:"name"`
+	Type      string      `json:"type"` // income 或 expense，子分类与父分类一致
+	Icon      string      `json:"icon"`
+	Color     string      `json:"color"`
+	SortOrder int         `json:"sort_order"`
+	Children  []*Category `json:"children,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type CreateCategoryRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Type      string `json:"type"` // 一级分类必填；子分类沿用父分类
+	ParentID  *int64 `json:"parent_id"`
+	Icon      string `json:"icon"`
+	Color     string `json:"color"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type UpdateCategoryRequest struct {
+	Name      *string `json:"name"`
+	Type      *string `json:"type"`      // 仅一级分类可修改，子分类随之变更
+	ParentID  *int64  `json:"parent_id"` // 传 0 表示提升为一级分类
+	Icon      *string `json:"icon"`
+	Color     *string `json:"color"`
+	SortOrder *int    `json:"sort_order"`
+}
+
+func ValidCategoryType(t string) bool {
+	return t == CategoryTypeIncome || t == CategoryTypeExpense
+}
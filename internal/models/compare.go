@@ -0,0 +1,93 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// 报表的对比方式
+const (
+	ComparePreviousPeriod     = "previous_period"       // 紧邻的上一个等长周期
+	CompareSamePeriodLastYear = "same_period_last_year" // 去年同期
+)
+
+// CompareThresholdDefault 分类增幅超过该百分比时标记
+const CompareThresholdDefault = 20.0
+
+func ValidCompare(mode string) bool {
+	return mode == ComparePreviousPeriod || mode == CompareSamePeriodLastYear
+}
+
+// CompareRange [start, end] 的对比区间。区间恰为若干个整月（按 c 的每月起始日划分）时按月平移，
+// 如 2 月的上一周期为 1 月；否则上一周期为紧邻的等天数区间，去年同期按日期减一年
+func (c Calendar) CompareRange(mode string, start, end time.Time) (time.Time, time.Time) {
+	next := end.AddDate(0, 0, 1)
+	if c.MonthStart(start).Equal(start) && c.MonthStart(next).Equal(next) {
+		months := (next.Year()-start.Year())*12 + int(next.Month()) - int(start.Month())
+		if mode == CompareSamePeriodLastYear {
+			months = 12
+		}
+		return start.AddDate(0, -months, 0), next.AddDate(0, -months, -1)
+	}
+	if mode == CompareSamePeriodLastYear {
+		return lastYear(start), lastYear(end)
+	}
+	days := int(next.Sub(start).Hours() / 24)
+	return start.AddDate(0, 0, -days), start.AddDate(0, 0, -1)
+}
+
+// lastYear 去年的同一天，闰日取去年 2 月 28 日（AddDate 会进位到 3 月 1 日）
+func lastYear(t time.Time) time.Time {
+	y := t.AddDate(-1, 0, 0)
+	if y.Day() != t.Day() {
+		y = y.AddDate(0, 0, -y.Day())
+	}
+	return y
+}
+
+// AmountDelta 金额与对比区间相比的变化，Percent 为相对对比值绝对值的百分比，对比值为 0 时为 null
+type AmountDelta struct {
+	Current  Money    `json:"current"`
+	Previous Money    `json:"previous"`
+	Change   Money    `json:"change"`
+	Percent  *float64 `json:"percent"`
+}
+
+// NewAmountDelta 计算 current 相对 previous 的变化，百分比保留一位小数
+func NewAmountDelta(current, previous Money) AmountDelta {
+	d := AmountDelta{Current: current, Previous: previous, Change: current - previous}
+	if previous != 0 {
+		p := math.Round(float64(d.Change)/float64(previous.Abs())*1000) / 10
+		d.Percent = &p
+	}
+	return d
+}
+
+// CategoryDelta 分类金额（收支绝对值）的变化。Flagged 表示增幅超过阈值或对比区间内没有该分类的收支
+type CategoryDelta struct {
+	CategoryID *int64 `json:"category_id"`
+	Category   string `json:"category"`
+	AmountDelta
+	Flagged bool `json:"flagged"`
+}
+
+// ReportComparison 报表与对比区间的比较
+type ReportComparison struct {
+	Mode       string           `json:"mode"`
+	StartDate  string           `json:"start_date"`
+	EndDate    string           `json:"end_date"`
+	Income     Money            `json:"income"` // 对比区间的合计
+	Expense    Money            `json:"expense"`
+	Balance    Money            `json:"balance"`
+	Count      int              `json:"count"`
+	Threshold  float64          `json:"threshold"` // 分类增幅标记阈值（百分比）
+	Delta      ReportDelta      `json:"delta"`
+	ByCategory []*CategoryDelta `json:"by_category"` // 按变化绝对值降序
+}
+
+// ReportDelta 收入、支出与结余的变化
+type ReportDelta struct {
+	Income  AmountDelta `json:"income"`
+	Expense AmountDelta `json:"expense"`
+	Balance AmountDelta `json:"balance"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestCalendarCompareRange(t *testing.T) {
+	custom := Calendar{MonthStartDay: 15}
+	tests := []struct {
+		name       string
+		cal        Calendar
+		mode       string
+		start, end string
+		wantStart  string
+		wantEnd    string
+	}{
+		{"month previous", Calendar{}, ComparePreviousPeriod, "2024-03-01", "2024-03-31", "2024-02-01", "2024-02-29"},
+		{"leap february previous", Calendar{}, ComparePreviousPeriod, "2024-02-01", "2024-02-29", "2024-01-01", "2024-01-31"},
+		{"leap february last year", Calendar{}, CompareSamePeriodLastYear, "2024-02-01", "2024-02-29", "2023-02-01", "2023-02-28"},
+		{"february after leap year", Calendar{}, CompareSamePeriodLastYear, "2025-02-01", "2025-02-28", "2024-02-01", "2024-02-29"},
+		{"quarter previous", Calendar{}, ComparePreviousPeriod, "2024-01-01", "2024-03-31", "2023-10-01", "2023-12-31"},
+		{"quarter last year", Calendar{}, CompareSamePeriodLastYear, "2024-01-01", "2024-03-31", "2023-01-01", "2023-03-31"},
+		{"year previous", Calendar{}, ComparePreviousPeriod, "2024-01-01", "2024-12-31", "2023-01-01", "2023-12-31"},
+		{"custom month previous", custom, ComparePreviousPeriod, "2024-03-15", "2024-04-14", "2024-02-15", "2024-03-14"},
+		{"custom month last year", custom, CompareSamePeriodLastYear, "2024-03-15", "2024-04-14", "2023-03-15", "2023-04-14"},
+		{"custom two months previous", custom, ComparePreviousPeriod, "2024-01-15", "2024-03-14", "2023-11-15", "2024-01-14"},
+		// 自然月在自定义起始日下不是整月，按等天数平移
+		{"natural month under custom calendar", custom, ComparePreviousPeriod, "2024-03-01", "2024-03-31", "2024-01-30", "2024-02-29"},
+		{"days previous", Calendar{}, ComparePreviousPeriod, "2024-03-10", "2024-03-16", "2024-03-03", "2024-03-09"},
+		{"days previous across leap day", Calendar{}, ComparePreviousPeriod, "2024-03-01", "2024-03-05", "2024-02-25", "2024-02-29"},
+		{"single day previous", Calendar{}, ComparePreviousPeriod, "2024-03-01", "2024-03-01", "2024-02-29", "2024-02-29"},
+		{"days last year", Calendar{}, CompareSamePeriodLastYear, "2024-03-10", "2024-03-16", "2023-03-10", "2023-03-16"},
+		{"leap day last year", Calendar{}, CompareSamePeriodLastYear, "2024-02-20", "2024-02-29", "2023-02-20", "2023-02-28"},
+		{"leap day as start last year", Calendar{}, CompareSamePeriodLastYear, "2024-02-29", "2024-03-10", "2023-02-28", "2023-03-10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.cal.CompareRange(tt.mode, date(tt.start), date(tt.end))
+			if got, want := start.Format("2006-01-02")+" ~ "+end.Format("2006-01-02"), tt.wantStart+" ~ "+tt.wantEnd; got != want {
+				t.Errorf("CompareRange(%s, %s, %s) = %s, want %s", tt.mode, tt.start, tt.end, got, want)
+			}
+		})
+	}
+}
+
+func TestNewAmountDelta(t *testing.T) {
+	tests := []struct {
+		cur, prev Money
+		change    Money
+		percent   *float64
+	}{
+		{1500, 1000, 500, ptr(50)},
+		{500, 1000, -500, ptr(-50)},
+		{-500, -1000, 500, ptr(50)}, // 相对对比值的绝对值
+		{1000, 3000, -2000, ptr(-66.7)},
+		{1000, 0, 1000, nil},
+	}
+	for _, tt := range tests {
+		d := NewAmountDelta(tt.cur, tt.prev)
+		if d.Change != tt.change {
+			t.Errorf("NewAmountDelta(%d, %d).Change = %d, want %d", tt.cur, tt.prev, d.Change, tt.change)
+		}
+		if (d.Percent == nil) != (tt.percent == nil) || (d.Percent != nil && *d.Percent != *tt.percent) {
+			t.Errorf("NewAmountDelta(%d, %d).Percent = %v, want %v", tt.cur, tt.prev, d.Percent, tt.percent)
+		}
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
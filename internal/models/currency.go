@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// NormalizeCurrency 规范化 ISO 4217 币种代码（三位大写字母），无效时返回 false
+func NormalizeCurrency(s string) (string, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 3 {
+		return "", false
+	}
+	for _, ch := range s {
+		if ch < 'A' || ch > 'Z' {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// ExchangeRate 某日汇率：1 单位 FromCurrency = Rate 单位 ToCurrency
+type ExchangeRate struct {
+	ID           int64     `json:"id"`
+	Date         string    `json:"date"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         float64   `json:"rate"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ExchangeRateInput struct {
+	Date         string  `json:"date" binding:"required"`
+	FromCurrency string  `json:"from_currency" binding:"required"`
+	ToCurrency   string  `json:"to_currency" binding:"required"`
+	Rate         float64 `json:"rate" binding:"required"`
+}
+
+type SaveExchangeRatesRequest struct {
+	Rates []ExchangeRateInput `json:"rates" binding:"required"`
+}
+
+type ExchangeRateQuery struct {
+	FromCurrency string `form:"from_currency"`
+	ToCurrency   string `form:"to_currency"`
+	StartDate    string `form:"start_date"`
+	EndDate      string `form:"end_date"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor 游标无法解析或与当前查询参数不匹配
+var ErrInvalidCursor = errors.New("cursor 无效")
+
+// RecordCursor 记录列表的游标：上一页末条记录的排序值与 ID。排序字段为 amount 时取 N，否则取 V
+type RecordCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	V     string `json:"v,omitempty"`
+	N     int64  `json:"n,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// LogCursor 操作日志列表的游标：上一页末条日志的 ID
+type LogCursor struct {
+	ID int64 `json:"id"`
+}
+
+// EncodeCursor 将游标编码为不透明字符串
+func EncodeCursor(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor 解析 EncodeCursor 生成的字符串
+func DecodeCursor(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, v) != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	in := RecordCursor{Sort: "category", Order: "asc", V: "餐饮 \"外卖\"", ID: 42}
+	var out RecordCursor
+	if err := DecodeCursor(EncodeCursor(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+
+	var log LogCursor
+	if err := DecodeCursor(EncodeCursor(LogCursor{ID: 7}), &log); err != nil || log.ID != 7 {
+		t.Errorf("LogCursor round trip = %+v, %v", log, err)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	valid := EncodeCursor(RecordCursor{Sort: "date", Order: "desc", V: "2024-03-01", ID: 9})
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"not base64", "!!!"},
+		{"padded std encoding", base64.StdEncoding.EncodeToString([]byte(`{"s":"date","o":"desc","id":9}`)) + "=="},
+		{"truncated", valid[:len(valid)-3]},
+		{"flipped char", "X" + valid[1:]},
+		{"not json", b64([]byte("hello"))},
+		{"wrong field type", b64([]byte(`{"s":"date","o":"desc","id":"9"}`))},
+		{"array", b64([]byte(`[1,2]`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cur RecordCursor
+			if err := DecodeCursor(tt.in, &cur); err != ErrInvalidCursor {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.in, err)
+			}
+		})
+	}
+}
+
+func TestQueryParamsCursor(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	tests := []struct {
+		name    string
+		sort    string
+		order   string
+		cursor  string
+		wantErr bool
+	}{
+		{"default sort", "", "", EncodeCursor(RecordCursor{Sort: "date", Order: "desc", V: "2024-03-01", ID: 9}), false},
+		{"amount", "amount", "asc", EncodeCursor(RecordCursor{Sort: "amount", Order: "asc", N: -1250, ID: 3}), false},
+		{"sort mismatch", "amount", "", EncodeCursor(RecordCursor{Sort: "date", Order: "desc", ID: 9}), true},
+		{"order mismatch", "", "asc", EncodeCursor(RecordCursor{Sort: "date", Order: "desc", ID: 9}), true},
+		{"zero id", "", "", EncodeCursor(RecordCursor{Sort: "date", Order: "desc", V: "2024-03-01"}), true},
+		{"negative id", "", "", b64([]byte(`{"s":"date","o":"desc","id":-1}`)), true},
+		{"null", "", "", b64([]byte(`null`)), true},
+		{"garbage", "", "", "%%%", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &QueryParams{Sort: tt.sort, Order: tt.order, Cursor: tt.cursor, CursorMode: true}
+			err := q.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (q.After == nil || q.After.ID <= 0) {
+				t.Errorf("Validate() After = %+v", q.After)
+			}
+		})
+	}
+}
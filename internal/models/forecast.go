@@ -0,0 +1,81 @@
+package models
+
+// Forecast 收支预测：以 Date（含当日）为止的实际数据，加上周期记账规则的已知收支，
+// 以及按历史日常收支（不含周期记账生成的记录）拟合的模型，预测本月与本财年末的收支与结余
+type Forecast struct {
+	Date     string           `json:"date"`
+	Currency string           `json:"currency"` // 本位币
+	Model    *ForecastModel   `json:"model"`    // 日常支出模型
+	Month    *PeriodForecast  `json:"month"`
+	Year     *PeriodForecast  `json:"year"`
+	Warnings []*ForecastAlert `json:"warnings"` // 预计超出预算的周期（仅限与本位币同币种的预算）
+}
+
+// ForecastModel 日常支出的拟合结果：Level 为近期移动平均的日均支出，
+// Weekday 为周日至周六的星期季节项（相对日均的增减），ResidualSD 为残差标准差
+type ForecastModel struct {
+	HistoryDays int         `json:"history_days"` // 实际用于拟合的天数
+	Window      int         `json:"window"`       // 移动平均窗口（天）
+	Level       Money       `json:"level"`
+	Weekday     [7]Money    `json:"weekday"`
+	ResidualSD  Money       `json:"residual_sd"`
+	Income      IncomeStats `json:"income"` // 日常收入模型
+}
+
+// IncomeStats 日常收入按不定期到账建模：周期内预计收入为 Level·周期天数扣除已到账部分，
+// Lump 为按金额加权的平均单笔规模，决定区间宽度
+type IncomeStats struct {
+	Level Money `json:"level"` // 日均收入
+	Lump  Money `json:"lump"`
+}
+
+// PeriodForecast 某一周期（月或财年）的预测。Actual* 为截至 Date 的实际收支（含周期记账），
+// Recurring* 为 Date 之后到周期结束按周期记账规则将要发生的收支
+type PeriodForecast struct {
+	Period           string           `json:"period"` // 2024-03 或 2024
+	StartDate        string           `json:"start_date"`
+	EndDate          string           `json:"end_date"`
+	DaysElapsed      int              `json:"days_elapsed"`
+	DaysRemaining    int              `json:"days_remaining"`
+	ActualIncome     Money            `json:"actual_income"`
+	ActualExpense    Money            `json:"actual_expense"`
+	RecurringIncome  Money            `json:"recurring_income"`
+	RecurringExpense Money            `json:"recurring_expense"`
+	Recurring        []*RecurringItem `json:"recurring"`
+	Income           ForecastValue    `json:"income"`
+	Expense          ForecastValue    `json:"expense"`
+	Balance          ForecastValue    `json:"balance"` // 收入 - 支出
+}
+
+// RecurringItem 预测范围内周期记账规则的一期
+type RecurringItem struct {
+	RuleID int64  `json:"rule_id"`
+	Name   string `json:"name"`
+	Date   string `json:"date"`
+	Amount Money  `json:"amount"` // 本位币，缺少汇率时不列出
+}
+
+// ForecastValue 周期末的预测值及置信区间
+type ForecastValue struct {
+	Projected Money            `json:"projected"`
+	Bands     []ConfidenceBand `json:"bands"`
+}
+
+// ConfidenceBand 置信水平 Level（如 0.8）下的区间
+type ConfidenceBand struct {
+	Level float64 `json:"level"`
+	Low   Money   `json:"low"`
+	High  Money   `json:"high"`
+}
+
+// ForecastAlert 预计支出超出预算（账本总预算或分类预算）
+type ForecastAlert struct {
+	Period      string  `json:"period"` // month 或 year
+	BudgetID    int64   `json:"budget_id"`
+	CategoryID  *int64  `json:"category_id"` // 分类预算的分类，总预算为空
+	Available   Money   `json:"available"`   // 本周期可用额度（含结转）
+	Projected   Money   `json:"projected"`
+	OverBy      Money   `json:"over_by"`
+	Probability float64 `json:"probability"` // 超支概率（0-1）
+	Message     string  `json:"message"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// IdempotencyRecord 幂等键对应的请求摘要与已保存的响应。Status 为 0 表示首个请求仍在处理中
+type IdempotencyRecord struct {
+	UserID      int64
+	Key         string
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// 账本成员角色（与全局 RoleAdmin/RoleUser 相互独立）
+const (
+	LedgerRoleOwner  = "owner"  // 所有者：管理成员及账本
+	LedgerRoleEditor = "editor" // 编辑者：可增删改记录
+	LedgerRoleViewer = "viewer" // 查看者：只读
+)
+
+// LedgerRoleRank 角色等级，数值越大权限越高；未知角色返回 0
+func LedgerRoleRank(role string) int {
+	switch role {
+	case LedgerRoleOwner:
+		return 3
+	case LedgerRoleEditor:
+		return 2
+	case LedgerRoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// Ledger 账本
+type Ledger struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int64     `json:"created_by"`
+	Role      string    `json:"role,omitempty"` // 当前用户在该账本中的角色
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerMember 账本成员
+type LedgerMember struct {
+	LedgerID  int64     `json:"ledger_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateLedgerRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type InviteMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role"` // 缺省为 viewer
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Money 金额，以分（1/100 元）为单位的整数存储，避免浮点累计误差。
+// JSON 中序列化为数字（如 -25.5），反序列化同时兼容数字与字符串（如 "-25.50"）。
+type Money int64
+
+// MoneyScale 每单位金额对应的最小单位数
+const MoneyScale = 100
+
+var errMoneyPrecision = errors.New("金额最多保留两位小数")
+
+// decimalPattern 普通十进制数。big.Rat 还接受指数、分数与 0x 等进制前缀，金额不允许
+// （指数如 1e1000000 会让 SetString 分配巨大的整数）
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)$`)
+
+// maxMoneyLen 金额字符串的最大长度，int64 分值加符号、小数点与末尾的 0 远不到此长度
+const maxMoneyLen = 32
+
+// ParseMoney 精确解析十进制金额字符串，超过两位小数时报错
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > maxMoneyLen || !decimalPattern.MatchString(s) {
+		return 0, fmt.Errorf("无效的金额: %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("无效的金额: %q", s)
+	}
+	r.Mul(r, big.NewRat(MoneyScale, 1))
+	if !r.IsInt() {
+		return 0, errMoneyPrecision
+	}
+	n := r.Num()
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("金额超出范围: %q", s)
+	}
+	return Money(n.Int64()), nil
+}
+
+// String 固定两位小数，如 -25.50
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/MoneyScale, v%MoneyScale)
+}
+
+// Abs 绝对值
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Float64 近似浮点值，仅用于统计计算（如百分比）
+func (m Money) Float64() float64 {
+	return float64(m) / MoneyScale
+}
+
+// MarshalJSON 输出为去掉末尾多余 0 的 JSON 数字，与原 float64 输出保持一致
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.String()
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return []byte(s), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return err
+		}
+		s = unquoted
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value 以整数（分）写入数据库
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		*m = Money(n)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*m = Money(n)
+	default:
+		return fmt.Errorf("无法将 %T 转换为 Money", src)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"12", 1200, false},
+		{"12.3", 1230, false},
+		{"12.34", 1234, false},
+		{"-25.5", -2550, false},
+		{"-0.01", -1, false},
+		{"+7", 700, false},
+		{".5", 50, false},
+		{"5.", 500, false},
+		{" 1.20 ", 120, false},
+		{"1.2300", 123, false}, // 末尾的 0 不算精度
+		{"1.005", 0, true},
+		{"0.001", 0, true},
+		{"1e-3", 0, true},
+		{"92233720368547758.08", 0, true}, // 超出 int64
+		{"", 0, true},
+		{"abc", 0, true},
+		{"1,000", 0, true},
+		{"1/4", 0, true},
+		{"0x10", 0, true},
+		{"0b1", 0, true},
+		{"1_000", 0, true},
+		{"Inf", 0, true},
+		{"NaN", 0, true},
+		{"--1", 0, true},
+		{"1e", 0, true},
+		{"1e2", 0, true}, // 不接受指数
+		{"1.5E1", 0, true},
+		{"1234e-2", 0, true},
+		{"-1e-2", 0, true},
+		{"1e1000000000", 0, true},
+		{"0.100000000000000000000000000000000", 0, true}, // 超过长度上限
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMoney(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1230, "12.30"},
+		{-2550, "-25.50"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0"},
+		{100, "1"},
+		{120, "1.2"},
+		{1234, "12.34"},
+		{-2550, "-25.5"},
+		{-1, "-0.01"},
+		{1000, "10"}, // 只去掉小数部分末尾的 0
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("Marshal(%d): %v", tt.in, err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("Marshal(%d) = %s, want %s", tt.in, b, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{`12.5`, 1250, false},
+		{`-25.5`, -2550, false},
+		{`"12.50"`, 1250, false},
+		{`"-0.01"`, -1, false},
+		{`0`, 0, false},
+		{`null`, 0, false}, // 保持原值
+		{`12.345`, 0, true},
+		{`"12.345"`, 0, true},
+		{`"1/4"`, 0, true},
+		{`""`, 0, true},
+		{`"abc"`, 0, true},
+		{`true`, 0, true},
+		{`1e2`, 0, true},
+		{`"1.5e1"`, 0, true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(tt.in), &m)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, m, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var v struct {
+		Amount Money `json:"amount"`
+	}
+	for _, m := range []Money{0, 1, -1, 99, 1234567, -987654321} {
+		v.Amount = m
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.Amount = 0
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", b, err)
+		}
+		if v.Amount != m {
+			t.Errorf("round trip %d via %s = %d", m, b, v.Amount)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PayeeMaxAliases 单个交易对方最多的别名数
+const PayeeMaxAliases = 20
+
+// Payee 账本内的交易对方（商户、付款人）。记账时按名称或别名（不区分大小写）匹配，
+// 找不到时自动创建；记录的 payee 保存匹配到的交易对方名称
+type Payee struct {
+	ID        int64     `json:"id"`
+	LedgerID  int64     `json:"ledger_id"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	UseCount  int       `json:"use_count"` // 关联的记录数（不含回收站）
+	LastUsed  string    `json:"last_used"` // 最近一笔记录的日期，未使用时为空
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreatePayeeRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Aliases []string `json:"aliases"`
+}
+
+type UpdatePayeeRequest struct {
+	Name    *string   `json:"name"`    // 改名会同步到已有记录
+	Aliases *[]string `json:"aliases"` // 整体替换，传 [] 清空
+}
+
+// PayeeItem 交易对方统计，未指定交易对方的记录不计入
+type PayeeItem struct {
+	PayeeID int64  `json:"payee_id"`
+	Payee   string `json:"payee"`
+	Income  Money  `json:"income"`
+	Expense Money  `json:"expense"`
+	Total   Money  `json:"total"` // 正为收入，负为支出
+	Count   int    `json:"count"`
+}
+
+// NormalizePayeeName 去除首尾空白并校验交易对方名称或别名
+func NormalizePayeeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("交易对方名称不能为空")
+	}
+	if len([]rune(name)) > 100 {
+		return "", errors.New("交易对方名称长度不能超过 100")
+	}
+	return name, nil
+}
+
+// NormalizeAliases 去除首尾空白、空值、与名称相同及重复（不区分大小写）的别名
+func NormalizeAliases(name string, aliases []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{strings.ToLower(name): true}
+	for _, a := range aliases {
+		if strings.TrimSpace(a) == "" {
+			continue
+		}
+		a, err := NormalizePayeeName(a)
+		if err != nil {
+			return nil, err
+		}
+		if key := strings.ToLower(a); !seen[key] {
+			seen[key] = true
+			out = append(out, a)
+		}
+	}
+	if len(out) > PayeeMaxAliases {
+		return nil, fmt.Errorf("别名不能超过 %d 个", PayeeMaxAliases)
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package models
+
+import "time"
+
+// 汇总的分组粒度
+const (
+	GroupByDay     = "day"
+	GroupByWeek    = "week"
+	GroupByMonth   = "month"
+	GroupByQuarter = "quarter"
+	GroupByYear    = "year"
+)
+
+// 周的起始日：iso 为 ISO 8601 周（周一开始，分项标记为 2024-W06），
+// 其余按本地习惯从指定的星期开始，分项以该周起始日期标记
+const (
+	WeekStartISO      = "iso"
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// MonthStartDayMax 每月起始日的上限，保证每个月都有这一天
+const MonthStartDayMax = 28
+
+// SummaryQuery 汇总引擎的查询参数，GroupBy 为空时不分项；月、季度、年按 Calendar 划分
+type SummaryQuery struct {
+	StartDate string
+	EndDate   string
+	GroupBy   string
+	Calendar
+}
+
+// Calendar 周期划分：周的起始日、每月起始日（如发薪日 15 表示每月 15 日至次月 14 日为一个月）
+// 与财年起始月。月以起始日所在月份命名，财年以起始日所在年份命名；零值按自然月、自然年
+type Calendar struct {
+	WeekStart      string
+	MonthStartDay  int
+	YearStartMonth int
+}
+
+func (c Calendar) monthDay() int {
+	if c.MonthStartDay < 1 || c.MonthStartDay > MonthStartDayMax {
+		return 1
+	}
+	return c.MonthStartDay
+}
+
+func (c Calendar) yearMonth() time.Month {
+	if c.YearStartMonth < 1 || c.YearStartMonth > 12 {
+		return time.January
+	}
+	return time.Month(c.YearStartMonth)
+}
+
+// WeekStartOf t 所在周的起始日，未设置时按 ISO 周从周一开始
+func (c Calendar) WeekStartOf(t time.Time) time.Time {
+	k, ok := WeekStartDays[c.WeekStart]
+	if !ok {
+		k = 1
+	}
+	return t.AddDate(0, 0, -((int(t.Weekday()) - k + 7) % 7))
+}
+
+// Natural 是否为自然月与自然年
+func (c Calendar) Natural() bool {
+	return c.monthDay() == 1 && c.yearMonth() == time.January
+}
+
+// MonthRange year 年 month 月对应的周期：当月起始日至次月起始日前一天
+func (c Calendar) MonthRange(year, month int) (start, end time.Time) {
+	start = time.Date(year, time.Month(month), c.monthDay(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, -1)
+}
+
+// YearRange 财年 year 对应的周期：year 年起始月的起始日起共 12 个月
+func (c Calendar) YearRange(year int) (start, end time.Time) {
+	start = time.Date(year, c.yearMonth(), c.monthDay(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(1, 0, -1)
+}
+
+// MonthStart t 所在月周期的起始日
+func (c Calendar) MonthStart(t time.Time) time.Time {
+	d := c.monthDay()
+	if t.Day() < d {
+		t = t.AddDate(0, 0, -t.Day()) // 上月最后一天
+	}
+	return time.Date(t.Year(), t.Month(), d, 0, 0, 0, 0, time.UTC)
+}
+
+// YearStart t 所在财年的起始日
+func (c Calendar) YearStart(t time.Time) time.Time {
+	m := c.MonthStart(t)
+	y := m.Year()
+	if m.Month() < c.yearMonth() {
+		y--
+	}
+	return time.Date(y, c.yearMonth(), c.monthDay(), 0, 0, 0, 0, time.UTC)
+}
+
+// QuarterStart t 所在财季的起始日，财季从财年起始月起每三个月一个
+func (c Calendar) QuarterStart(t time.Time) time.Time {
+	y := c.YearStart(t)
+	m := c.MonthStart(t)
+	months := (m.Year()-y.Year())*12 + int(m.Month()) - int(y.Month())
+	return y.AddDate(0, months/3*3, 0)
+}
+
+// FiscalYear t 所在财年的名称（起始日所在年份）
+func (c Calendar) FiscalYear(t time.Time) int {
+	return c.YearStart(t).Year()
+}
+
+func ValidGroupBy(g string) bool {
+	switch g {
+	case GroupByDay, GroupByWeek, GroupByMonth, GroupByQuarter, GroupByYear:
+		return true
+	}
+	return false
+}
+
+func ValidWeekStart(w string) bool {
+	_, ok := WeekStartDays[w]
+	return ok
+}
+
+// WeekStartDays 周起始日对应的星期（0 为周日）
+var WeekStartDays = map[string]int{
+	WeekStartISO:      1,
+	WeekStartMonday:   1,
+	WeekStartSunday:   0,
+	WeekStartSaturday: 6,
+}
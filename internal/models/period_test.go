@@ -0,0 +1,134 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCalendarMonthStart(t *testing.T) {
+	tests := []struct {
+		day  int
+		in   string
+		want string
+	}{
+		{0, "2024-03-01", "2024-03-01"},
+		{1, "2024-03-31", "2024-03-01"},
+		{15, "2024-03-15", "2024-03-15"},
+		{15, "2024-03-14", "2024-02-15"},
+		{15, "2024-01-10", "2023-12-15"}, // 跨年
+		{28, "2024-02-29", "2024-02-28"},
+		{28, "2024-03-27", "2024-02-28"},
+		{28, "2023-03-01", "2023-02-28"},
+		// 超过 MonthStartDayMax 的起始日按自然月处理
+		{29, "2024-03-15", "2024-03-01"},
+		{30, "2023-02-28", "2023-02-01"},
+		{31, "2024-04-30", "2024-04-01"},
+	}
+	for _, tt := range tests {
+		c := Calendar{MonthStartDay: tt.day}
+		if got := c.MonthStart(date(tt.in)).Format("2006-01-02"); got != tt.want {
+			t.Errorf("MonthStart(day=%d, %s) = %s, want %s", tt.day, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarYearStart(t *testing.T) {
+	tests := []struct {
+		cal  Calendar
+		in   string
+		want string
+		fy   int
+	}{
+		{Calendar{}, "2024-12-31", "2024-01-01", 2024},
+		{Calendar{YearStartMonth: 4}, "2024-04-01", "2024-04-01", 2024},
+		{Calendar{YearStartMonth: 4}, "2024-03-31", "2023-04-01", 2023},
+		{Calendar{YearStartMonth: 7}, "2025-01-15", "2024-07-01", 2024},
+		{Calendar{YearStartMonth: 7}, "2024-06-30", "2023-07-01", 2023},
+		// 起始日与起始月同时设置：4 月 15 日之前仍属上一财年
+		{Calendar{YearStartMonth: 4, MonthStartDay: 15}, "2024-04-14", "2023-04-15", 2023},
+		{Calendar{YearStartMonth: 4, MonthStartDay: 15}, "2024-04-15", "2024-04-15", 2024},
+		{Calendar{YearStartMonth: 1, MonthStartDay: 25}, "2024-01-10", "2023-01-25", 2023},
+		{Calendar{YearStartMonth: 1, MonthStartDay: 25}, "2024-01-25", "2024-01-25", 2024},
+		{Calendar{YearStartMonth: 13}, "2024-06-01", "2024-01-01", 2024}, // 无效月份按自然年
+	}
+	for _, tt := range tests {
+		got := tt.cal.YearStart(date(tt.in))
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("%+v YearStart(%s) = %s, want %s", tt.cal, tt.in, got.Format("2006-01-02"), tt.want)
+		}
+		if fy := tt.cal.FiscalYear(date(tt.in)); fy != tt.fy {
+			t.Errorf("%+v FiscalYear(%s) = %d, want %d", tt.cal, tt.in, fy, tt.fy)
+		}
+	}
+}
+
+func TestCalendarQuarterStart(t *testing.T) {
+	tests := []struct {
+		cal  Calendar
+		in   string
+		want string
+	}{
+		{Calendar{}, "2024-02-29", "2024-01-01"},
+		{Calendar{}, "2024-04-01", "2024-04-01"},
+		{Calendar{}, "2024-12-31", "2024-10-01"},
+		{Calendar{YearStartMonth: 4}, "2024-03-31", "2024-01-01"}, // 2023 财年第四季度
+		{Calendar{YearStartMonth: 4}, "2024-07-01", "2024-07-01"},
+		{Calendar{YearStartMonth: 7}, "2024-09-30", "2024-07-01"},
+		{Calendar{YearStartMonth: 7}, "2025-02-01", "2025-01-01"},
+		{Calendar{YearStartMonth: 2}, "2024-01-31", "2023-11-01"},
+		{Calendar{YearStartMonth: 4, MonthStartDay: 15}, "2024-07-14", "2024-04-15"},
+		{Calendar{YearStartMonth: 4, MonthStartDay: 15}, "2024-07-15", "2024-07-15"},
+	}
+	for _, tt := range tests {
+		if got := tt.cal.QuarterStart(date(tt.in)).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%+v QuarterStart(%s) = %s, want %s", tt.cal, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarWeekStartOf(t *testing.T) {
+	// 2024-01-07 为周日，2024-01-06 为周六
+	tests := []struct {
+		week string
+		in   string
+		want string
+	}{
+		{"", "2024-01-07", "2024-01-01"},
+		{WeekStartISO, "2024-01-01", "2024-01-01"},
+		{WeekStartMonday, "2024-01-07", "2024-01-01"},
+		{WeekStartSunday, "2024-01-07", "2024-01-07"},
+		{WeekStartSunday, "2024-01-06", "2023-12-31"},
+		{WeekStartSaturday, "2024-01-06", "2024-01-06"},
+		{WeekStartSaturday, "2024-01-05", "2023-12-30"},
+	}
+	for _, tt := range tests {
+		c := Calendar{WeekStart: tt.week}
+		if got := c.WeekStartOf(date(tt.in)).Format("2006-01-02"); got != tt.want {
+			t.Errorf("WeekStartOf(%q, %s) = %s, want %s", tt.week, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarRanges(t *testing.T) {
+	c := Calendar{MonthStartDay: 25, YearStartMonth: 4}
+	start, end := c.MonthRange(2024, 1)
+	if start.Format("2006-01-02") != "2024-01-25" || end.Format("2006-01-02") != "2024-02-24" {
+		t.Errorf("MonthRange(2024, 1) = %s ~ %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	start, end = c.YearRange(2024)
+	if start.Format("2006-01-02") != "2024-04-25" || end.Format("2006-01-02") != "2025-04-24" {
+		t.Errorf("YearRange(2024) = %s ~ %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	start, end = Calendar{}.MonthRange(2024, 2)
+	if start.Format("2006-01-02") != "2024-02-01" || end.Format("2006-01-02") != "2024-02-29" {
+		t.Errorf("MonthRange(2024, 2) = %s ~ %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+}
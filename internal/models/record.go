@@ -1,37 +1,245 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Record struct {
-	ID          int64     `json:"id"`
-	Date        string    `json:"date" binding:"required"`         // 日期 YYYY-MM-DD
-	Amount      float64   `json:"amount" binding:"required"`       // 金额，正数为收入，负数为支出
-	Category    string    `json:"category"`                        // 分类
-	Description string    `json:"description"`                     // 描述/备注
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              int64      `json:"id"`
+	LedgerID        int64      `json:"ledger_id"`                 // 所属账本
+	UserID          int64      `json:"user_id"`                   // 创建者
+	AccountID       *int64     `json:"account_id"`                // 资金账户，可为空
+	TransferID      *int64     `json:"transfer_id"`               // 所属转账，非空时为转账分录
+	RecurringRuleID *int64     `json:"recurring_rule_id"`         // 由周期记账规则生成时为规则 ID
+	Date            string     `json:"date" binding:"required"`   // 日期 YYYY-MM-DD
+	Amount          Money      `json:"amount" binding:"required"` // 金额，正数为收入，负数为支出
+	Currency        string     `json:"currency"`                  // 币种
+	CategoryID      *int64     `json:"category_id"`               // 分类，可为空
+	Category        string     `json:"category"`                  // 分类名称
+	Description     string     `json:"description"`               // 描述/备注
+	Payee           string     `json:"payee"`                     // 交易对方（商户、付款人）
+	PayeeID         *int64     `json:"payee_id"`                  // 关联的交易对方，可为空
+	Snippet         string     `json:"snippet,omitempty"`         // 关键字搜索时的高亮片段
+	Tags            []string   `json:"tags"`                      // 标签
+	Splits          []*Split   `json:"splits,omitempty"`          // 拆分行，非空时按各行分类统计
+	Version         int64      `json:"version"`                   // 版本号，每次修改递增，用于 If-Match
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"` // 移入回收站的时间
 }
 
 type CreateRecordRequest struct {
-	Date        string  `json:"date" binding:"required"`
-	Amount      float64 `json:"amount" binding:"required"`
-	Category    string  `json:"category"`
-	Description string  `json:"description"`
+	Date        string   `json:"date" binding:"required"`
+	Amount      Money    `json:"amount" binding:"required"`
+	Currency    string   `json:"currency"` // 缺省为账户币种或用户本位币
+	CategoryID  *int64   `json:"category_id"`
+	Category    string   `json:"category"` // 未给出 category_id 时按名称匹配分类，不存在则自动创建
+	Description string   `json:"description"`
+	Payee       string   `json:"payee"`    // 未给出 payee_id 时按名称或别名匹配交易对方，不存在则自动创建
+	PayeeID     *int64   `json:"payee_id"` // 给出时 payee 取该交易对方的名称
+	AccountID   *int64   `json:"account_id"`
+	Tags        []string `json:"tags"`
+	Splits      []*Split `json:"splits"` // 拆分行，给出时记录本身不设分类
 }
 
 type UpdateRecordRequest struct {
-	Date        *string  `json:"date"`
-	Amount      *float64 `json:"amount"`
-	Category    *string  `json:"category"`
-	Description *string  `json:"description"`
+	Date        *string   `json:"date"`
+	Amount      *Money    `json:"amount"`
+	Currency    *string   `json:"currency"`
+	CategoryID  *int64    `json:"category_id"` // 传 0 表示取消分类
+	Category    *string   `json:"category"`
+	Description *string   `json:"description"`
+	Payee       *string   `json:"payee"`
+	PayeeID     *int64    `json:"payee_id"`   // 传 0 表示取消交易对方
+	AccountID   *int64    `json:"account_id"` // 传 0 表示取消关联账户
+	Tags        *[]string `json:"tags"`       // 整体替换，传 [] 清空
+	Splits      *[]*Split `json:"splits"`     // 整体替换，传 [] 取消拆分
 }
 
 type QueryParams struct {
-	StartDate string `form:"start_date"` // 起始日期
-	EndDate   string `form:"end_date"`   // 结束日期
-	Keyword   string `form:"keyword"`    // 关键字搜索（描述、分类）
-	Page      int    `form:"page"`
-	PageSize  int    `form:"page_size"`
+	StartDate  string `form:"start_date" json:"start_date"`   // 起始日期
+	EndDate    string `form:"end_date" json:"end_date"`       // 结束日期
+	Keyword    string `form:"keyword" json:"keyword"`         // 关键字全文搜索（描述、分类、交易对方），支持 "短语" 与 前缀*
+	CategoryID int64  `form:"category_id" json:"category_id"` // 分类筛选，含其子分类
+	PayeeID    int64  `form:"payee_id" json:"payee_id"`       // 交易对方筛选
+	Tags       string `form:"tags" json:"tags"`               // 标签筛选，逗号分隔
+	TagMode    string `form:"tag_mode" json:"tag_mode"`       // any（默认，含任一标签）或 all（含全部标签）
+	Page       int    `form:"page" json:"page"`
+	PageSize   int    `form:"page_size" json:"page_size"`
+
+	Sort        string `form:"sort" json:"sort"`                 // 排序字段：date、amount、category、created_at、relevance（有关键字时默认）
+	Order       string `form:"order" json:"order"`               // asc 或 desc（默认）
+	Type        string `form:"type" json:"type"`                 // income 仅收入，expense 仅支出
+	MinAmount   string `form:"min_amount" json:"min_amount"`     // 金额绝对值下限
+	MaxAmount   string `form:"max_amount" json:"max_amount"`     // 金额绝对值上限
+	CategoryIDs string `form:"category_ids" json:"category_ids"` // 多个分类，逗号分隔，各含其子分类
+	CreatedFrom string `form:"created_from" json:"created_from"` // 创建时间范围，YYYY-MM-DD 或 RFC3339
+	CreatedTo   string `form:"created_to" json:"created_to"`
+	UpdatedFrom string `form:"updated_from" json:"updated_from"` // 更新时间范围，YYYY-MM-DD 或 RFC3339
+	UpdatedTo   string `form:"updated_to" json:"updated_to"`
+	Cursor      string `form:"cursor" json:"cursor"`         // 游标分页：上一页返回的 next_cursor，首页传空
+	WithTotal   *bool  `form:"with_total" json:"with_total"` // 是否统计总数，分页模式缺省为是，游标模式缺省为否
+
+	// 以下由 Validate 解析填充
+	MinAmountValue   *Money        `form:"-" json:"-"`
+	MaxAmountValue   *Money        `form:"-" json:"-"`
+	CategoryIDList   []int64       `form:"-" json:"-"`
+	TimeRanges       [4]string     `form:"-" json:"-"` // created_from, created_to, updated_from, updated_to，UTC "2006-01-02 15:04:05"
+	CursorMode       bool          `form:"-" json:"-"` // 请求中带 cursor 参数（可为空）时为游标模式
+	ExcludeTransfers bool          `form:"-" json:"-"` // 排除转账分录
+	After            *RecordCursor `form:"-" json:"-"`
+}
+
+// SortField 生效的排序字段：未指定时有关键字按相关度（游标分页除外），否则按日期
+func (q *QueryParams) SortField() string {
+	if q.Sort != "" {
+		return q.Sort
+	}
+	if strings.TrimSpace(q.Keyword) != "" && !q.CursorMode {
+		return SortRelevance
+	}
+	return "date"
+}
+
+// SortOrder 生效的排序方向
+func (q *QueryParams) SortOrder() string {
+	if q.Order == "" {
+		return "desc"
+	}
+	return q.Order
+}
+
+// HasFilter 是否给出了任一筛选条件（不含分页与排序）
+func (q *QueryParams) HasFilter() bool {
+	return q.StartDate != "" || q.EndDate != "" || strings.TrimSpace(q.Keyword) != "" || q.CategoryID > 0 || q.PayeeID > 0 ||
+		q.Tags != "" || q.Type != "" || q.MinAmount != "" || q.MaxAmount != "" || q.CategoryIDs != "" ||
+		q.CreatedFrom != "" || q.CreatedTo != "" || q.UpdatedFrom != "" || q.UpdatedTo != ""
+}
+
+// WantTotal 是否需要统计总数
+func (q *QueryParams) WantTotal() bool {
+	if q.WithTotal != nil {
+		return *q.WithTotal
+	}
+	return !q.CursorMode
+}
+
+// SortRelevance 按搜索相关度排序，仅在有关键字且非游标分页时可用
+const SortRelevance = "relevance"
+
+// 记录列表可用的排序字段
+var RecordSortFields = map[string]bool{"date": true, "amount": true, "category": true, "created_at": true, SortRelevance: true}
+
+// Validate 校验并解析排序与筛选参数
+func (q *QueryParams) Validate() error {
+	if q.Sort != "" && !RecordSortFields[q.Sort] {
+		return errors.New("sort 须为 date、amount、category、created_at 或 relevance")
+	}
+	if q.Sort == SortRelevance && (strings.TrimSpace(q.Keyword) == "" || q.CursorMode) {
+		return errors.New("relevance 排序须提供 keyword，且不支持游标分页")
+	}
+	if q.Order != "" && q.Order != "asc" && q.Order != "desc" {
+		return errors.New("order 须为 asc 或 desc")
+	}
+	if q.TagMode != "" && q.TagMode != TagModeAny && q.TagMode != TagModeAll {
+		return errors.New("tag_mode 须为 any 或 all")
+	}
+	if q.Type != "" && q.Type != CategoryTypeIncome && q.Type != CategoryTypeExpense {
+		return errors.New("type 须为 income 或 expense")
+	}
+	for _, d := range []struct {
+		name string
+		val  string
+	}{{"start_date", q.StartDate}, {"end_date", q.EndDate}} {
+		if d.val != "" {
+			if _, err := time.Parse("2006-01-02", d.val); err != nil {
+				return fmt.Errorf("%s 须为 YYYY-MM-DD", d.name)
+			}
+		}
+	}
+	for _, a := range []struct {
+		name string
+		val  string
+		dst  **Money
+	}{{"min_amount", q.MinAmount, &q.MinAmountValue}, {"max_amount", q.MaxAmount, &q.MaxAmountValue}} {
+		if a.val == "" {
+			continue
+		}
+		m, err := ParseMoney(a.val)
+		if err != nil || m < 0 {
+			return fmt.Errorf("%s 须为非负金额", a.name)
+		}
+		*a.dst = &m
+	}
+	if q.MinAmountValue != nil && q.MaxAmountValue != nil && *q.MinAmountValue > *q.MaxAmountValue {
+		return errors.New("min_amount 不能大于 max_amount")
+	}
+	q.CategoryIDList = nil
+	for _, s := range strings.Split(q.CategoryIDs, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			return errors.New("category_ids 须为逗号分隔的分类 ID")
+		}
+		q.CategoryIDList = append(q.CategoryIDList, id)
+	}
+	for i, t := range []struct {
+		name  string
+		val   string
+		isEnd bool
+	}{{"created_from", q.CreatedFrom, false}, {"created_to", q.CreatedTo, true}, {"updated_from", q.UpdatedFrom, false}, {"updated_to", q.UpdatedTo, true}} {
+		q.TimeRanges[i] = ""
+		if t.val == "" {
+			continue
+		}
+		v, err := parseTimeBound(t.val, t.isEnd)
+		if err != nil {
+			return fmt.Errorf("%s 须为 YYYY-MM-DD 或 RFC3339 时间", t.name)
+		}
+		q.TimeRanges[i] = v
+	}
+	q.After = nil
+	if q.CursorMode && q.Cursor != "" {
+		var cur RecordCursor
+		if err := DecodeCursor(q.Cursor, &cur); err != nil || cur.ID <= 0 {
+			return ErrInvalidCursor
+		}
+		if cur.Sort != q.SortField() || cur.Order != q.SortOrder() {
+			return errors.New("cursor 与 sort/order 不一致")
+		}
+		q.After = &cur
+	}
+	return nil
+}
+
+// parseTimeBound 将日期或 RFC3339 时间转为与 CURRENT_TIMESTAMP 一致的 UTC 格式；只给日期时，上界取当天结束
+func parseTimeBound(s string, isEnd bool) (string, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if isEnd {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format("2006-01-02 15:04:05"), nil
+}
+
+// TagList 解析 tags 参数
+func (q *QueryParams) TagList() []string {
+	if q.Tags == "" {
+		return nil
+	}
+	tags, _ := NormalizeTags(strings.Split(q.Tags, ","))
+	return tags
 }
 
 func (q *QueryParams) Normalize() {
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 周期频率，与 RRULE 的 FREQ 对应
+const (
+	FreqDaily   = "daily"
+	FreqWeekly  = "weekly"
+	FreqMonthly = "monthly"
+	FreqYearly  = "yearly"
+)
+
+// RecurringRule 周期记账规则：从 StartDate 起每 Interval 个 Freq 生成一条记录，直到 EndDate（为空表示不结束）。
+// 按月/按年时以 StartDate 的日为准，当月没有该日时取月末（如 1 月 31 日起按月为 2 月 29 日）
+type RecurringRule struct {
+	ID          int64     `json:"id"`
+	LedgerID    int64     `json:"ledger_id"`
+	UserID      int64     `json:"user_id"` // 创建者，生成的记录归属该用户
+	Name        string    `json:"name"`
+	Freq        string    `json:"freq"`
+	Interval    int       `json:"interval"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"`
+	CategoryID  *int64    `json:"category_id"`
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	AccountID   *int64    `json:"account_id"`
+	Active      bool      `json:"active"`
+	Seq         int       `json:"-"`         // 下一期的序号（从 0 开始）
+	Version     int64     `json:"-"`         // 每次修改规则递增，调度器据此发现补记期间规则的变更
+	NextDate    string    `json:"next_date"` // 下一次生成日期，规则结束后为空
+	RRule       string    `json:"rrule"`     // RRULE 形式的频率描述，只读
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateRecurringRuleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Freq        string `json:"freq" binding:"required"`
+	Interval    int    `json:"interval"` // 缺省为 1
+	StartDate   string `json:"start_date" binding:"required"`
+	EndDate     string `json:"end_date"`
+	Amount      Money  `json:"amount" binding:"required"`
+	Currency    string `json:"currency"`
+	CategoryID  *int64 `json:"category_id"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	AccountID   *int64 `json:"account_id"`
+}
+
+type UpdateRecurringRuleRequest struct {
+	Name        *string `json:"name"`
+	Freq        *string `json:"freq"`
+	Interval    *int    `json:"interval"`
+	StartDate   *string `json:"start_date"`
+	EndDate     *string `json:"end_date"` // 传空串表示不结束
+	Amount      *Money  `json:"amount"`
+	Currency    *string `json:"currency"`
+	CategoryID  *int64  `json:"category_id"` // 传 0 表示不分类
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	AccountID   *int64  `json:"account_id"` // 传 0 表示取消关联账户
+	Active      *bool   `json:"active"`     // false 暂停
+}
+
+func ValidFreq(f string) bool {
+	switch f {
+	case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+		return true
+	}
+	return false
+}
+
+// Occurrence 第 n 期（从 0 开始）的日期
+func (r *RecurringRule) Occurrence(n int) string {
+	start, err := time.Parse("2006-01-02", r.StartDate)
+	if err != nil {
+		return ""
+	}
+	step := n * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		return start.AddDate(0, 0, step).Format("2006-01-02")
+	case FreqWeekly:
+		return start.AddDate(0, 0, 7*step).Format("2006-01-02")
+	case FreqYearly:
+		step *= 12
+	}
+	// 按月推进时先定位到目标月 1 日，再取不超过月末的日
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, step, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1).Format("2006-01-02")
+}
+
+// Schedule 从第 n 期起找到首个晚于 after 的期数及日期；超出 EndDate 时日期为空
+func (r *RecurringRule) Schedule(n int, after string) (int, string) {
+	for {
+		d := r.Occurrence(n)
+		if d == "" || (r.EndDate != "" && d > r.EndDate) {
+			return n, ""
+		}
+		if d > after {
+			return n, d
+		}
+		n++
+	}
+}
+
+// FormatRRule 生成 RRULE 描述，如 FREQ=MONTHLY;INTERVAL=1;UNTIL=20261231
+func (r *RecurringRule) FormatRRule() string {
+	freq := map[string]string{FreqDaily: "DAILY", FreqWeekly: "WEEKLY", FreqMonthly: "MONTHLY", FreqYearly: "YEARLY"}[r.Freq]
+	s := fmt.Sprintf("FREQ=%s;INTERVAL=%d", freq, r.Interval)
+	if r.EndDate != "" && len(r.EndDate) == 10 {
+		s += ";UNTIL=" + r.EndDate[:4] + r.EndDate[5:7] + r.EndDate[8:]
+	}
+	return s
+}
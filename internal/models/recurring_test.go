@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestRecurringRuleOccurrence(t *testing.T) {
+	tests := []struct {
+		name     string
+		freq     string
+		interval int
+		start    string
+		want     []string // 第 0、1、2… 期
+	}{
+		{"daily", FreqDaily, 1, "2024-02-27", []string{"2024-02-27", "2024-02-28", "2024-02-29", "2024-03-01"}},
+		{"every 10 days", FreqDaily, 10, "2023-12-25", []string{"2023-12-25", "2024-01-04", "2024-01-14"}},
+		{"weekly", FreqWeekly, 1, "2024-12-25", []string{"2024-12-25", "2025-01-01", "2025-01-08"}},
+		{"biweekly", FreqWeekly, 2, "2024-02-15", []string{"2024-02-15", "2024-02-29", "2024-03-14"}},
+		// 月末夹取不累积：2 月取月末后 3 月仍回到 31 日
+		{"month end leap year", FreqMonthly, 1, "2024-01-31", []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"}},
+		{"month end common year", FreqMonthly, 1, "2023-01-31", []string{"2023-01-31", "2023-02-28", "2023-03-31"}},
+		{"day 30", FreqMonthly, 1, "2023-12-30", []string{"2023-12-30", "2024-01-30", "2024-02-29", "2024-03-30"}},
+		{"quarterly", FreqMonthly, 3, "2024-11-30", []string{"2024-11-30", "2025-02-28", "2025-05-30", "2025-08-30"}},
+		{"yearly leap day", FreqYearly, 1, "2024-02-29", []string{"2024-02-29", "2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29"}},
+		{"every 2 years", FreqYearly, 2, "2023-06-15", []string{"2023-06-15", "2025-06-15", "2027-06-15"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RecurringRule{Freq: tt.freq, Interval: tt.interval, StartDate: tt.start}
+			for n, want := range tt.want {
+				if got := r.Occurrence(n); got != want {
+					t.Errorf("Occurrence(%d) = %s, want %s", n, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurringRuleOccurrenceInvalidStart(t *testing.T) {
+	r := &RecurringRule{Freq: FreqMonthly, Interval: 1, StartDate: "2024-13-01"}
+	if got := r.Occurrence(0); got != "" {
+		t.Errorf("Occurrence(0) = %q, want empty", got)
+	}
+}
+
+func TestRecurringRuleSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RecurringRule
+		n        int
+		after    string
+		wantN    int
+		wantDate string
+	}{
+		{"first", RecurringRule{Freq: FreqMonthly, Interval: 1, StartDate: "2024-01-31"}, 0, "", 0, "2024-01-31"},
+		{"skip to after", RecurringRule{Freq: FreqMonthly, Interval: 1, StartDate: "2024-01-31"}, 0, "2024-03-15", 2, "2024-03-31"},
+		{"after equals occurrence", RecurringRule{Freq: FreqWeekly, Interval: 1, StartDate: "2024-01-01"}, 0, "2024-01-08", 2, "2024-01-15"},
+		{"from n", RecurringRule{Freq: FreqDaily, Interval: 1, StartDate: "2024-01-01"}, 5, "", 5, "2024-01-06"},
+		{"end date inclusive", RecurringRule{Freq: FreqMonthly, Interval: 1, StartDate: "2024-01-31", EndDate: "2024-03-31"}, 2, "", 2, "2024-03-31"},
+		{"past end date", RecurringRule{Freq: FreqMonthly, Interval: 1, StartDate: "2024-01-31", EndDate: "2024-03-30"}, 2, "", 2, ""},
+		{"after past end date", RecurringRule{Freq: FreqYearly, Interval: 1, StartDate: "2024-02-29", EndDate: "2026-12-31"}, 0, "2026-03-01", 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, d := tt.rule.Schedule(tt.n, tt.after)
+			if n != tt.wantN || d != tt.wantDate {
+				t.Errorf("Schedule(%d, %q) = (%d, %q), want (%d, %q)", tt.n, tt.after, n, d, tt.wantN, tt.wantDate)
+			}
+		})
+	}
+}
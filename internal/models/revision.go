@@ -0,0 +1,124 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 记录修订的操作类型
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+)
+
+// RecordSnapshot 记录在某一版本的可编辑字段
+type RecordSnapshot struct {
+	Date        string   `json:"date"`
+	Amount      Money    `json:"amount"`
+	Currency    string   `json:"currency"`
+	CategoryID  *int64   `json:"category_id"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Payee       string   `json:"payee"`
+	AccountID   *int64   `json:"account_id"`
+	Tags        []string `json:"tags"`
+	Splits      []*Split `json:"splits,omitempty"`
+}
+
+// SnapshotOf 取记录当前的可编辑字段
+func SnapshotOf(r *Record) *RecordSnapshot {
+	tags := r.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &RecordSnapshot{
+		Date:        r.Date,
+		Amount:      r.Amount,
+		Currency:    r.Currency,
+		CategoryID:  r.CategoryID,
+		Category:    r.Category,
+		Description: r.Description,
+		Payee:       r.Payee,
+		AccountID:   r.AccountID,
+		Tags:        tags,
+		Splits:      r.Splits,
+	}
+}
+
+// snapshotFields 参与比较的字段名，顺序即 changes 的输出顺序
+var snapshotFields = []string{"date", "amount", "currency", "category_id", "category", "description", "payee", "account_id", "tags", "splits"}
+
+// values 按 snapshotFields 顺序返回可比较的字段值，快照为 nil 时均为 nil；ID 为 0 视同未设置
+func (s *RecordSnapshot) values() []interface{} {
+	if s == nil {
+		return make([]interface{}, len(snapshotFields))
+	}
+	var splits interface{} // 未拆分时为 nil，避免与空切片比较出差异
+	if len(s.Splits) > 0 {
+		splits = s.Splits
+	}
+	return []interface{}{s.Date, s.Amount, s.Currency, optionalID(s.CategoryID), s.Category, s.Description, s.Payee, optionalID(s.AccountID), s.Tags, splits}
+}
+
+func optionalID(p *int64) interface{} {
+	if p == nil || *p == 0 {
+		return nil
+	}
+	return *p
+}
+
+// FieldChange 单个字段的变化，创建时 Old 为 null，删除时 New 为 null
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// DiffSnapshots 比较两个版本，返回有变化的字段
+func DiffSnapshots(before, after *RecordSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	b, a := before.values(), after.values()
+	for i, field := range snapshotFields {
+		if !sameValue(b[i], a[i]) {
+			changes = append(changes, FieldChange{Field: field, Old: b[i], New: a[i]})
+		}
+	}
+	return changes
+}
+
+func sameValue(x, y interface{}) bool {
+	xs, xok := x.([]*Split)
+	ys, yok := y.([]*Split)
+	if xok || yok {
+		return xok == yok && sameSplits(xs, ys)
+	}
+	xt, xok := x.([]string)
+	yt, yok := y.([]string)
+	if xok || yok {
+		return xok == yok && strings.Join(xt, "\x00") == strings.Join(yt, "\x00")
+	}
+	return x == y
+}
+
+// RecordRevision 记录的一个修订版本：Before 为修改前，After 为修改后（删除时为 null）
+type RecordRevision struct {
+	ID        int64           `json:"id"`
+	RecordID  int64           `json:"record_id"`
+	LedgerID  int64           `json:"ledger_id"`
+	UserID    int64           `json:"user_id"` // 操作者
+	Username  string          `json:"username"`
+	Action    string          `json:"action"`
+	RevertOf  *int64          `json:"revert_of,omitempty"` // 回滚时为目标版本 ID
+	Before    *RecordSnapshot `json:"before"`
+	After     *RecordSnapshot `json:"after"`
+	Changes   []FieldChange   `json:"changes"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RevertRecordRequest 回滚记录到指定版本
+type RevertRecordRequest struct {
+	RevisionID int64 `json:"revision_id" binding:"required"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SplitMaxLines 单条记录最多的拆分行数
+const SplitMaxLines = 50
+
+// Split 拆分记录的一行：一笔交易（如一张超市小票）按分类拆成多行，各行金额之和等于记录金额。
+// 请求中未给出 category_id 时按 category 名称匹配分类，不存在则自动创建
+type Split struct {
+	CategoryID *int64 `json:"category_id"`
+	Category   string `json:"category"`
+	Amount     Money  `json:"amount"`
+	Note       string `json:"note"`
+}
+
+// ValidateSplits 校验拆分行：至少两行，各行金额非 0 且与记录金额同号，合计等于 total
+func ValidateSplits(total Money, splits []*Split) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	if len(splits) < 2 {
+		return errors.New("拆分至少需要两行")
+	}
+	if len(splits) > SplitMaxLines {
+		return fmt.Errorf("拆分最多 %d 行", SplitMaxLines)
+	}
+	var sum Money
+	for i, s := range splits {
+		if s == nil || s.Amount == 0 {
+			return fmt.Errorf("第 %d 行拆分金额不能为 0", i+1)
+		}
+		if (s.Amount > 0) != (total > 0) {
+			return fmt.Errorf("第 %d 行拆分金额须与记录金额同为收入或支出", i+1)
+		}
+		sum += s.Amount
+	}
+	if sum != total {
+		return fmt.Errorf("拆分合计 %s 与记录金额 %s 不一致", sum, total)
+	}
+	return nil
+}
+
+// sameSplits 比较两组拆分行（按顺序），ID 为 0 视同未设置
+func sameSplits(x, y []*Split) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		a, b := x[i], y[i]
+		if !sameValue(optionalID(a.CategoryID), optionalID(b.CategoryID)) ||
+			a.Category != b.Category || a.Amount != b.Amount || a.Note != b.Note {
+			return false
+		}
+	}
+	return true
+}
@@ -1,42 +1,93 @@
 package models
 
-// Summary 汇总数据
+// Summary 汇总数据，金额均为本位币
 type Summary struct {
-	Income   float64 `json:"income"`   // 收入总额
-	Expense  float64 `json:"expense"`  // 支出总额
-	Balance  float64 `json:"balance"`  // 结余 (收入-支出)
-	Count    int     `json:"count"`    // 记录数
-	Records  []*Record `json:"records,omitempty"`  // 明细（日汇总用）
-	Breakdown []*BreakdownItem `json:"breakdown,omitempty"` // 分项（月/年用）
+	StartDate   string           `json:"start_date,omitempty"` // 统计范围
+	EndDate     string           `json:"end_date,omitempty"`
+	Currency    string           `json:"currency"`              // 本位币
+	Income      Money            `json:"income"`                // 收入总额
+	Expense     Money            `json:"expense"`               // 支出总额
+	Balance     Money            `json:"balance"`               // 结余 (收入-支出)
+	Count       int              `json:"count"`                 // 记录数
+	Records     []*Record        `json:"records,omitempty"`     // 明细（日汇总用）
+	Breakdown   []*BreakdownItem `json:"breakdown,omitempty"`   // 分项（月/年用）
+	ByAccount   []*AccountItem   `json:"by_account,omitempty"`  // 按账户（月/年用）
+	ByCurrency  []*CurrencyItem  `json:"by_currency,omitempty"` // 按原币种
+	Unconverted int              `json:"unconverted"`           // 缺少汇率、未计入合计的记录数
 }
 
 // BreakdownItem 分项数据
 type BreakdownItem struct {
-	Period   string  `json:"period"`   // 日期/月份
-	Income   float64 `json:"income"`
-	Expense  float64 `json:"expense"`
-	Balance  float64 `json:"balance"`
-	Count    int     `json:"count"`
+	Period    string `json:"period"`     // 日期/周/月份/季度/年份
+	StartDate string `json:"start_date"` // 分项的起止日期，不超出查询范围
+	EndDate   string `json:"end_date"`
+	Income    Money  `json:"income"`
+	Expense   Money  `json:"expense"`
+	Balance   Money  `json:"balance"`
+	Count     int    `json:"count"`
 }
 
-// CategoryItem 分类统计
+// AccountItem 账户统计，AccountID 为空表示未指定账户的记录
+type AccountItem struct {
+	AccountID *int64 `json:"account_id"`
+	Account   string `json:"account"`
+	Income    Money  `json:"income"`
+	Expense   Money  `json:"expense"`
+	Balance   Money  `json:"balance"`
+	Count     int    `json:"count"`
+}
+
+// CurrencyItem 原币种统计：Income/Expense/Balance 为原币金额，Converted* 为换算后的本位币金额
+type CurrencyItem struct {
+	Currency         string `json:"currency"`
+	Income           Money  `json:"income"`
+	Expense          Money  `json:"expense"`
+	Balance          Money  `json:"balance"`
+	ConvertedIncome  Money  `json:"converted_income"`
+	ConvertedExpense Money  `json:"converted_expense"`
+	ConvertedBalance Money  `json:"converted_balance"`
+	Count            int    `json:"count"`
+	Unconverted      int    `json:"unconverted"` // 缺少汇率的记录数
+}
+
+// CategoryItem 分类统计，CategoryID 为空表示未分类；一级分类的 Children 为各子分类（含直接记在一级分类下的记录）
 type CategoryItem struct {
-	Category string  `json:"category"`
-	Income   float64 `json:"income"`
-	Expense  float64 `json:"expense"`
-	Total    float64 `json:"total"` // 正为收入，负为支出
-	Count    int     `json:"count"`
+	CategoryID *int64          `json:"category_id"`
+	Category   string          `json:"category"`
+	Income     Money           `json:"income"`
+	Expense    Money           `json:"expense"`
+	Total      Money           `json:"total"` // 正为收入，负为支出
+	Count      int             `json:"count"`
+	Children   []*CategoryItem `json:"children,omitempty"`
 }
 
 // Report 报表
 type Report struct {
-	StartDate   string             `json:"start_date"`
-	EndDate     string             `json:"end_date"`
-	Income      float64            `json:"income"`
-	Expense     float64            `json:"expense"`
-	Balance     float64            `json:"balance"`
-	Count       int                `json:"count"`
-	Daily       []*BreakdownItem   `json:"daily"`   // 按日
-	Monthly     []*BreakdownItem   `json:"monthly"` // 按月
-	ByCategory  []*CategoryItem    `json:"by_category"`
+	StartDate   string            `json:"start_date"`
+	EndDate     string            `json:"end_date"`
+	CategoryID  *int64            `json:"category_id,omitempty"` // 下钻的分类，非空时仅统计该分类及其子分类
+	Currency    string            `json:"currency"`              // 本位币
+	Income      Money             `json:"income"`
+	Expense     Money             `json:"expense"`
+	Balance     Money             `json:"balance"`
+	Count       int               `json:"count"`
+	Daily       []*BreakdownItem  `json:"daily"`   // 按日
+	Monthly     []*BreakdownItem  `json:"monthly"` // 按月
+	ByCategory  []*CategoryItem   `json:"by_category"`
+	ByTag       []*TagItem        `json:"by_tag"`            // 按标签
+	ByPayee     []*PayeeItem      `json:"by_payee"`          // 按交易对方
+	ByCurrency  []*CurrencyItem   `json:"by_currency"`       // 按原币种
+	Unconverted int               `json:"unconverted"`       // 缺少汇率、未计入合计的记录数
+	Compare     *ReportComparison `json:"compare,omitempty"` // 与对比区间的比较，传 compare 时返回
+}
+
+// ReportQuery 报表参数：CategoryID 非 0 时下钻到该分类；Compare 非空时与对比区间比较，
+// 分类增幅超过 Threshold（百分比）时标记
+type ReportQuery struct {
+	StartDate  string
+	EndDate    string
+	CategoryID int64
+	Compare    string
+	Threshold  float64
+	Calendar
 }
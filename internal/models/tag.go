@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// 标签筛选方式
+const (
+	TagModeAny = "any" // 含任一标签
+	TagModeAll = "all" // 含全部标签
+)
+
+// Tag 账本内的标签，与记录多对多关联
+type Tag struct {
+	ID        int64     `json:"id"`
+	LedgerID  int64     `json:"ledger_id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"` // 关联记录数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagItem 标签统计；一条记录可带多个标签，各标签合计之和可能大于总额
+type TagItem struct {
+	TagID   int64  `json:"tag_id"`
+	Tag     string `json:"tag"`
+	Income  Money  `json:"income"`
+	Expense Money  `json:"expense"`
+	Total   Money  `json:"total"` // 正为收入，负为支出
+	Count   int    `json:"count"`
+}
+
+// NormalizeTags 去除首尾空白、空值与重复标签；标签名不能含逗号（筛选参数以逗号分隔）
+func NormalizeTags(names []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" || seen[n] {
+			continue
+		}
+		if strings.Contains(n, ",") {
+			return nil, errors.New("标签不能包含逗号")
+		}
+		if len([]rune(n)) > 50 {
+			return nil, errors.New("标签长度不能超过 50")
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out, nil
+}
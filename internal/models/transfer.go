@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// TransferCategory 转账两条分录使用的分类名
+const TransferCategory = "转账"
+
+// Transfer 账户间转账，由一条转出记录（负数）和一条转入记录（正数）组成，不计入收支
+type Transfer struct {
+	ID            int64     `json:"id"`
+	LedgerID      int64     `json:"ledger_id"`
+	UserID        int64     `json:"user_id"`
+	Date          string    `json:"date"`
+	Amount        Money     `json:"amount"`    // 转出金额（转出账户币种），恒为正
+	ToAmount      Money     `json:"to_amount"` // 转入金额（转入账户币种），同币种时等于 amount
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	FromCurrency  string    `json:"from_currency"`
+	ToCurrency    string    `json:"to_currency"`
+	Description   string    `json:"description"`
+	OutRecordID   int64     `json:"out_record_id"` // 转出分录
+	InRecordID    int64     `json:"in_record_id"`  // 转入分录
+	Version       int64     `json:"version"`       // 版本号，每次修改递增，用于 If-Match
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type CreateTransferRequest struct {
+	Date          string `json:"date" binding:"required"`
+	Amount        Money  `json:"amount" binding:"required"`
+	ToAmount      Money  `json:"to_amount"` // 跨币种转账时必填
+	FromAccountID int64  `json:"from_account_id" binding:"required"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required"`
+	Description   string `json:"description"`
+}
+
+type UpdateTransferRequest struct {
+	Date          *string `json:"date"`
+	Amount        *Money  `json:"amount"`
+	ToAmount      *Money  `json:"to_amount"`
+	FromAccountID *int64  `json:"from_account_id"`
+	ToAccountID   *int64  `json:"to_account_id"`
+	Description   *string `json:"description"`
+}
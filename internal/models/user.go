@@ -29,3 +29,24 @@ type RegisterRequest struct {
 type ChangePasswordRequest struct {
 	Password string `json:"password" binding:"required"`
 }
+
+// UserSettings 用户偏好设置
+type UserSettings struct {
+	BaseCurrency string `json:"base_currency"` // 本位币，汇总与报表金额统一换算为该币种
+	WeekStart    string `json:"week_start"`    // 按周汇总时周的起始日，iso/monday/sunday/saturday
+	// 每月起始日（1-28）与财年起始月（1-12），月度/年度汇总、预算与报表按此划分周期
+	MonthStartDay  int `json:"month_start_day"`
+	YearStartMonth int `json:"year_start_month"`
+}
+
+// Calendar 用户的周期划分
+func (s *UserSettings) Calendar() Calendar {
+	return Calendar{WeekStart: s.WeekStart, MonthStartDay: s.MonthStartDay, YearStartMonth: s.YearStartMonth}
+}
+
+type UpdateSettingsRequest struct {
+	BaseCurrency   *string `json:"base_currency"`
+	WeekStart      *string `json:"week_start"`
+	MonthStartDay  *int    `json:"month_start_day"`
+	YearStartMonth *int    `json:"year_start_month"`
+}
@@ -0,0 +1,135 @@
+// Package scheduler 定时将到期的周期记账规则生成为记录，并清理超过保留期的回收站记录与过期的幂等键
+package scheduler
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/models"
+	"account-service/internal/storage"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// maxPostsPerRule 每次执行时单条规则最多补记的期数，其余留待下次执行，避免一次写入过多记录
+const maxPostsPerRule = 366
+
+type Scheduler struct {
+	db             *database.DB
+	files          storage.Storage
+	interval       time.Duration
+	trashRetention time.Duration
+}
+
+// New 创建调度器，files 为附件存储（清理回收站时删除附件文件），trashRetention 为 0 时不自动清理回收站
+func New(db *database.DB, files storage.Storage, interval, trashRetention time.Duration) *Scheduler {
+	return &Scheduler{db: db, files: files, interval: interval, trashRetention: trashRetention}
+}
+
+// Start 立即执行一次，之后每 interval 检查一次，直到 ctx 结束。应在独立 goroutine 中调用
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(time.Now().Format("2006-01-02")); err != nil {
+			log.Printf("周期记账: %v", err)
+		}
+		s.purgeTrash()
+		if _, err := s.db.PurgeIdempotencyKeys(time.Now()); err != nil {
+			log.Printf("清理幂等键: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 为所有到期规则补齐截至 today 的记录。停机期间错过的周期会逐期补记（每条规则每次至多 maxPostsPerRule 期）；
+// 每期入账与推进进度在同一事务中，且 (规则, 日期) 唯一，重启或重复执行不会重复入账；补记期间规则被暂停或修改时停止补记
+func (s *Scheduler) RunOnce(today string) error {
+	rules, err := s.db.DueRecurringRules(today)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := s.post(rule, today); err != nil {
+			log.Printf("周期记账规则 %d: %v", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) post(rule *models.RecurringRule, today string) error {
+	username := ""
+	if u, err := s.db.GetUserByID(rule.UserID); err == nil && u != nil {
+		username = u.Username
+	}
+	// 分类或账户已被删除时不再关联，仍按模板入账
+	if rule.CategoryID != nil {
+		if cat, err := s.db.GetCategory(rule.LedgerID, *rule.CategoryID); err != nil {
+			return err
+		} else if cat == nil {
+			rule.CategoryID = nil
+		} else {
+			rule.Category = cat.Name
+		}
+	}
+	if rule.AccountID != nil {
+		if a, err := s.db.GetAccount(rule.LedgerID, *rule.AccountID); err != nil {
+			return err
+		} else if a == nil || a.Currency != rule.Currency {
+			rule.AccountID = nil
+		}
+	}
+	date := rule.NextDate
+	for posted := 0; date != "" && date <= today && posted < maxPostsPerRule; posted++ {
+		r := &models.Record{
+			LedgerID:        rule.LedgerID,
+			UserID:          rule.UserID,
+			AccountID:       rule.AccountID,
+			RecurringRuleID: &rule.ID,
+			Date:            date,
+			Amount:          rule.Amount,
+			Currency:        rule.Currency,
+			CategoryID:      rule.CategoryID,
+			Category:        rule.Category,
+			Description:     rule.Description,
+		}
+		seq, next := rule.Schedule(rule.Seq+1, "")
+		created, err := s.db.PostRecurring(rule, r, seq, next)
+		if errors.Is(err, database.ErrRecurringChanged) {
+			// 规则已被暂停、删除或修改，剩余各期按新内容留待下次执行
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if created {
+			_ = s.db.LogOperation(rule.UserID, username, database.OpRecurringRecord, "record", strconv.FormatInt(r.ID, 10),
+				rule.Name+" "+date+" "+r.Amount.String(), "", "scheduler")
+		}
+		rule.Seq, date = seq, next
+	}
+	return nil
+}
+
+// purgeTrash 彻底删除移入回收站超过保留期的记录
+func (s *Scheduler) purgeTrash() {
+	if s.trashRetention <= 0 {
+		return
+	}
+	n, keys, err := s.db.PurgeTrashBefore(time.Now().Add(-s.trashRetention))
+	if err != nil {
+		log.Printf("清理回收站: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("清理回收站: 彻底删除 %d 条记录", n)
+	}
+	if err := storage.DeleteKeys(s.files, keys); err != nil {
+		log.Printf("清理回收站附件: %v", err)
+	}
+}
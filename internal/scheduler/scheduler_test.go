@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"account-service/internal/database"
+	"account-service/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunOnce(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := New(db, nil, 0, 0)
+	rule := &models.RecurringRule{LedgerID: 1, UserID: 1, Name: "早餐", Freq: models.FreqDaily, Interval: 1,
+		StartDate: "2023-01-01", Amount: -1500, Currency: "CNY", Active: true}
+	if err := db.CreateRecurringRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int64 {
+		_, total, _, err := db.List(1, &models.QueryParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+
+	// 每次执行至多补记 maxPostsPerRule 期，其余留待下次
+	if err := s.RunOnce("2024-12-31"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != maxPostsPerRule {
+		t.Fatalf("after first run: %d records, want %d", n, maxPostsPerRule)
+	}
+	if err := s.RunOnce("2024-12-31"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 731 {
+		t.Fatalf("after second run: %d records, want 731", n)
+	}
+	// 重复执行不会重复入账
+	if err := s.RunOnce("2024-12-31"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 731 {
+		t.Errorf("after third run: %d records, want 731", n)
+	}
+	got, _ := db.GetRecurringRule(1, rule.ID)
+	if got.NextDate != "2025-01-01" {
+		t.Errorf("next_date = %s, want 2025-01-01", got.NextDate)
+	}
+}
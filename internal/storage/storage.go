@@ -0,0 +1,101 @@
+// Package storage 附件文件的存储后端
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 按 key 存取文件的存储后端。key 为以 / 分隔的相对路径
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Local 本地文件系统存储，文件保存在 root 目录下
+type Local struct {
+	root string
+}
+
+// NewLocal 创建本地存储，root 不存在时自动创建
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+// path 将 key 映射为 root 下的文件路径，拒绝绝对路径与越出 root 的 key
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("无效的存储 key")
+	}
+	return filepath.Join(l.root, clean), nil
+}
+
+// Put 写入文件：先写临时文件再重命名，写入中途失败不会留下不完整的文件
+func (l *Local) Put(key string, r io.Reader) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete 删除文件，文件不存在时视为成功
+func (l *Local) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeleteKeys 依次删除多个文件，返回遇到的第一个错误（其余文件仍会尝试删除）
+func DeleteKeys(s Storage, keys []string) error {
+	var first error
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
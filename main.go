@@ -5,6 +5,10 @@ import (
 	"account-service/internal/database"
 	"account-service/internal/handlers"
 	"account-service/internal/middleware"
+	"account-service/internal/models"
+	"account-service/internal/scheduler"
+	"account-service/internal/storage"
+	"context"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +21,13 @@ func main() {
 		log.Fatal(err)
 	}
 	defer db.Close()
+	files, err := storage.NewLocal(cfg.AttachmentDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 周期记账：启动时补记停机期间到期的记录，之后定时检查
+	go scheduler.New(db, files, cfg.SchedulerInterval, cfg.TrashRetention).Start(context.Background())
 
 	r := gin.Default()
 
@@ -24,7 +35,8 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -33,7 +45,7 @@ func main() {
 	})
 
 	api := r.Group("/api")
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret)
+	authHandler := handlers.NewAuthHandler(db, files, cfg.JWTSecret)
 
 	// 无需认证
 	api.GET("/auth/register/status", authHandler.RegisterStatus)
@@ -59,20 +71,114 @@ func main() {
 			admin.POST("/auth/users/:id/change-password", authHandler.AdminChangeUserPassword)
 			admin.GET("/auth/operation-logs", authHandler.ListOperationLogs)
 		}
+		exchangeRateHandler := handlers.NewExchangeRateHandler(db)
+		auth.GET("/exchange-rates", exchangeRateHandler.ListRates)
+		admin.POST("/exchange-rates", exchangeRateHandler.SaveRates)
+		admin.POST("/exchange-rates/import", exchangeRateHandler.ImportRates)
+		admin.DELETE("/exchange-rates/:id", exchangeRateHandler.DeleteRate)
 		auth.POST("/auth/totp/enable", authHandler.TOTPEnable)
 		auth.POST("/auth/totp/disable", authHandler.TOTPDisable)
 
-		recordHandler := handlers.NewRecordHandler(db)
+		settingsHandler := handlers.NewSettingsHandler(db)
+		auth.GET("/settings", settingsHandler.GetSettings)
+		auth.PUT("/settings", settingsHandler.UpdateSettings)
+
+		ledgerHandler := handlers.NewLedgerHandler(db)
+		auth.GET("/ledgers", ledgerHandler.ListLedgers)
+		auth.POST("/ledgers", ledgerHandler.CreateLedger)
+
+		// 创建类接口支持 Idempotency-Key 去重
+		idempotent := middleware.Idempotency(db, cfg.IdempotencyTTL)
+
+		// 账本内操作：按 ledger_id（缺省为默认账本）校验成员角色
+		viewer := auth.Group("")
+		viewer.Use(middleware.Ledger(db, models.LedgerRoleViewer))
+		editor := auth.Group("")
+		editor.Use(middleware.Ledger(db, models.LedgerRoleEditor))
+		owner := auth.Group("")
+		owner.Use(middleware.Ledger(db, models.LedgerRoleOwner))
+
+		viewer.GET("/ledgers/:ledger_id/members", ledgerHandler.ListMembers)
+		owner.POST("/ledgers/:ledger_id/members", ledgerHandler.InviteMember)
+		owner.PUT("/ledgers/:ledger_id/members/:user_id", ledgerHandler.UpdateMemberRole)
+		owner.DELETE("/ledgers/:ledger_id/members/:user_id", ledgerHandler.RemoveMember)
+
+		accountHandler := handlers.NewAccountHandler(db)
+		viewer.GET("/accounts", accountHandler.ListAccounts)
+		viewer.GET("/accounts/balances", accountHandler.Balances)
+		viewer.GET("/accounts/:id", accountHandler.GetAccount)
+		editor.POST("/accounts", accountHandler.CreateAccount)
+		editor.PUT("/accounts/:id", accountHandler.UpdateAccount)
+		editor.DELETE("/accounts/:id", accountHandler.DeleteAccount)
+
+		categoryHandler := handlers.NewCategoryHandler(db)
+		viewer.GET("/categories", categoryHandler.ListCategories)
+		viewer.GET("/categories/:id", categoryHandler.GetCategory)
+		editor.POST("/categories", categoryHandler.CreateCategory)
+		editor.PUT("/categories/:id", categoryHandler.UpdateCategory)
+		editor.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+
+		payeeHandler := handlers.NewPayeeHandler(db)
+		viewer.GET("/payees", payeeHandler.ListPayees)
+		viewer.GET("/payees/:id", payeeHandler.GetPayee)
+		editor.POST("/payees", payeeHandler.CreatePayee)
+		editor.PUT("/payees/:id", payeeHandler.UpdatePayee)
+		editor.DELETE("/payees/:id", payeeHandler.DeletePayee)
+
+		tagHandler := handlers.NewTagHandler(db)
+		viewer.GET("/tags", tagHandler.ListTags)
+		editor.DELETE("/tags/:id", tagHandler.DeleteTag)
+
+		budgetHandler := handlers.NewBudgetHandler(db)
+		viewer.GET("/budgets", budgetHandler.ListBudgets)
+		viewer.GET("/budgets/status", budgetHandler.Status)
+		viewer.GET("/budgets/:id", budgetHandler.GetBudget)
+		editor.POST("/budgets", budgetHandler.CreateBudget)
+		editor.PUT("/budgets/:id", budgetHandler.UpdateBudget)
+		editor.DELETE("/budgets/:id", budgetHandler.DeleteBudget)
+
+		recurringHandler := handlers.NewRecurringHandler(db)
+		viewer.GET("/recurring", recurringHandler.ListRecurringRules)
+		viewer.GET("/recurring/:id", recurringHandler.GetRecurringRule)
+		editor.POST("/recurring", recurringHandler.CreateRecurringRule)
+		editor.PUT("/recurring/:id", recurringHandler.UpdateRecurringRule)
+		editor.DELETE("/recurring/:id", recurringHandler.DeleteRecurringRule)
+
+		transferHandler := handlers.NewTransferHandler(db)
+		viewer.GET("/transfers", transferHandler.ListTransfers)
+		viewer.GET("/transfers/:id", transferHandler.GetTransfer)
+		editor.POST("/transfers", idempotent, transferHandler.CreateTransfer)
+		editor.PUT("/transfers/:id", transferHandler.UpdateTransfer)
+		editor.DELETE("/transfers/:id", transferHandler.DeleteTransfer)
+
+		recordHandler := handlers.NewRecordHandler(db, files)
 		summaryHandler := handlers.NewSummaryHandler(db)
-		auth.GET("/records", recordHandler.ListRecords)
-		auth.GET("/records/:id", recordHandler.GetRecord)
-		auth.POST("/records", recordHandler.CreateRecord)
-		auth.PUT("/records/:id", recordHandler.UpdateRecord)
-		auth.DELETE("/records/:id", recordHandler.DeleteRecord)
-		auth.GET("/summary/daily", summaryHandler.DailySummary)
-		auth.GET("/summary/monthly", summaryHandler.MonthlySummary)
-		auth.GET("/summary/yearly", summaryHandler.YearlySummary)
-		auth.GET("/report", summaryHandler.Report)
+		viewer.GET("/records", recordHandler.ListRecords)
+		viewer.GET("/records/:id", recordHandler.GetRecord)
+		viewer.GET("/records/:id/history", recordHandler.RecordHistory)
+		editor.POST("/records/:id/revert", recordHandler.RevertRecord)
+		editor.POST("/records", idempotent, recordHandler.CreateRecord)
+		editor.POST("/records/batch", idempotent, recordHandler.Batch)
+		viewer.GET("/records/trash", recordHandler.ListTrash)
+		editor.POST("/records/trash/:id/restore", recordHandler.RestoreRecord)
+		editor.DELETE("/records/trash/:id", recordHandler.PurgeRecord)
+		owner.DELETE("/records/trash", recordHandler.EmptyTrash)
+		editor.PUT("/records/:id", recordHandler.UpdateRecord)
+		editor.DELETE("/records/:id", recordHandler.DeleteRecord)
+		attachmentHandler := handlers.NewAttachmentHandler(db, files, cfg.AttachmentMaxSize)
+		viewer.GET("/records/:id/attachments", attachmentHandler.ListAttachments)
+		viewer.GET("/records/:id/attachments/:aid", attachmentHandler.DownloadAttachment)
+		viewer.GET("/records/:id/attachments/:aid/thumbnail", attachmentHandler.DownloadThumbnail)
+		editor.POST("/records/:id/attachments", attachmentHandler.UploadAttachment)
+		editor.DELETE("/records/:id/attachments/:aid", attachmentHandler.DeleteAttachment)
+		viewer.GET("/summary", summaryHandler.Summary)
+		viewer.GET("/summary/daily", summaryHandler.DailySummary)
+		viewer.GET("/summary/monthly", summaryHandler.MonthlySummary)
+		viewer.GET("/summary/yearly", summaryHandler.YearlySummary)
+		viewer.GET("/report", summaryHandler.Report)
+
+		forecastHandler := handlers.NewForecastHandler(db)
+		viewer.GET("/forecast", forecastHandler.Forecast)
 	}
 
 	// 前端静态文件（放 /app 下避免与 /api 路由冲突）